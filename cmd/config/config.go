@@ -0,0 +1,220 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/core/experimentation"
+	"github.com/nicholastcs/alchemy/internal/environment"
+	"github.com/nicholastcs/alchemy/internal/system"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func NewCommand(log *logrus.Entry) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:           "config view|use-config|set",
+		Short:         "To view or modify the config file.",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Example: `To view the config file:
+  alchemy config view
+
+To select the config 'platform':
+  alchemy config use-config platform
+
+To create or update the config 'platform':
+  alchemy config set platform --config-namespace k8s.io --config-source dir://./manifests`,
+
+		// config file is managed without loading the environment, so
+		// that the broken manifest sources can be fixed from here.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(newViewCommand())
+	configCmd.AddCommand(newUseConfigCommand(log))
+	configCmd.AddCommand(newSetCommand(log))
+
+	return configCmd
+}
+
+func newViewCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:           "view",
+		Short:         "To view the config file.",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, config, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if config == nil {
+				return fmt.Errorf("config file '%s' not found", path)
+			}
+
+			m, err := core.ConvertToAbstractedManifest(config)
+			if err != nil {
+				return err
+			}
+
+			return experimentation.DisplaySingle(*m)
+		},
+	}
+}
+
+func newUseConfigCommand(log *logrus.Entry) *cobra.Command {
+	return &cobra.Command{
+		Use:           "use-config <config-name>",
+		Short:         "To select the config of the config file.",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, config, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if config == nil {
+				return fmt.Errorf("config file '%s' not found", path)
+			}
+
+			err = config.SelectConfig(args[0])
+			if err != nil {
+				return err
+			}
+
+			err = environment.SaveConfig(path, config)
+			if err != nil {
+				return err
+			}
+
+			log.Debugf("config '%s' selected in '%s'", args[0], path)
+			utils.Tell("Config selected", fmt.Sprintf("Config '%s' is selected in '%s'.", args[0], path))
+
+			return nil
+		},
+	}
+}
+
+func newSetCommand(log *logrus.Entry) *cobra.Command {
+	var (
+		configNamespace string
+		configLogLevel  string
+		configSources   []string
+		configStorage   string
+		configRetention int
+		allowOverrides  bool
+		enableLogs      bool
+	)
+
+	setCmd := &cobra.Command{
+		Use:           "set <config-name> [--config-namespace=<namespace>] [--config-source=<source>] [--config-log-level=<level>] [--config-storage=file|memory] [--config-retention=<count>] [--config-allow-overrides]",
+		Short:         "To create or update the config of the config file.",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			path, config, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if config == nil {
+				config = environment.NewConfig()
+			}
+
+			i := -1
+			for j, c := range config.Spec.Configs {
+				if c.Name == name {
+					i = j
+				}
+			}
+			if i < 0 {
+				config.Spec.Configs = append(config.Spec.Configs, core.SubConfig{Name: name})
+				i = len(config.Spec.Configs) - 1
+			}
+
+			// only the flags that are set will be updated
+			sub := &config.Spec.Configs[i]
+			if cmd.Flags().Changed("config-namespace") {
+				sub.Namespace = configNamespace
+			}
+			if cmd.Flags().Changed("config-log-level") {
+				sub.LogLevel = configLogLevel
+			}
+			if cmd.Flags().Changed("config-source") {
+				sub.Sources = configSources
+			}
+			if cmd.Flags().Changed("config-storage") {
+				sub.Storage = configStorage
+			}
+			if cmd.Flags().Changed("config-retention") {
+				sub.Retention = configRetention
+			}
+			if cmd.Flags().Changed("config-allow-overrides") {
+				sub.AllowOverrides = allowOverrides
+			}
+			if cmd.Flags().Changed("enable-logs") {
+				config.Spec.EnableLogs = &enableLogs
+			}
+
+			// the very first config is selected by default
+			if config.Spec.SelectedConfig == "" {
+				config.Spec.SelectedConfig = name
+			}
+
+			err = environment.SaveConfig(path, config)
+			if err != nil {
+				return errors.Join(errors.New("unable to save config file"), err)
+			}
+
+			log.Debugf("config '%s' saved in '%s'", name, path)
+			utils.Tell("Config saved", fmt.Sprintf("Config '%s' is saved in '%s'.", name, path))
+
+			return nil
+		},
+	}
+
+	setCmd.Flags().StringVar(&configNamespace, "config-namespace", "", "default namespace of the config")
+	setCmd.Flags().StringVar(&configLogLevel, "config-log-level", "", "default log level of the config")
+	setCmd.Flags().StringArrayVar(&configSources, "config-source", []string{}, "manifest source of the config (can be repeated)")
+	setCmd.Flags().StringVar(&configStorage, "config-storage", "", "storage of the config, either file or memory")
+	setCmd.Flags().IntVar(&configRetention, "config-retention", 0, fmt.Sprintf("number of form results kept by the file storage, where 0 keeps the default of %d", system.DefaultRetention))
+	setCmd.Flags().BoolVar(&allowOverrides, "config-allow-overrides", false, "let the latter manifest source override the same resource of the former sources")
+	setCmd.Flags().BoolVar(&enableLogs, "enable-logs", true, "enable logging into ./alchemy.log of the working directory")
+
+	return setCmd
+}
+
+// loadConfig loads the config file from the path of CLI flag --config,
+// else from the default path.
+func loadConfig(cmd *cobra.Command) (string, *core.ConfigManifest, error) {
+	path, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return "", nil, err
+	}
+
+	if path == "" {
+		path, err = environment.DefaultConfigPath()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	config, err := environment.LoadConfig(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return path, config, nil
+}
@@ -3,6 +3,7 @@ package get
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/nicholastcs/alchemy/internal/apis/core/experimentation"
 	"github.com/nicholastcs/alchemy/internal/system"
@@ -73,6 +74,12 @@ To retrieve APIs of kind 'codeTemplate':
 				return err
 			}
 
+			// core APIs are not scoped by the namespace, where they are
+			// always under the `default` namespace.
+			if strings.HasPrefix(apiVersion, "alchemy.io/core") {
+				namespace = "default"
+			}
+
 			// retrieve list of same API
 			if len(args) == 1 {
 				manifests, err := db.GetByGVKNs(apiVersion, kind, namespace)
@@ -3,15 +3,19 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/goccy/go-yaml"
+	configcmd "github.com/nicholastcs/alchemy/cmd/config"
 	"github.com/nicholastcs/alchemy/cmd/docs"
 	"github.com/nicholastcs/alchemy/cmd/get"
 	"github.com/nicholastcs/alchemy/cmd/run"
+	"github.com/nicholastcs/alchemy/cmd/upgrade"
 	"github.com/nicholastcs/alchemy/internal/apis/core"
 	"github.com/nicholastcs/alchemy/internal/apis/core/experimentation"
 	"github.com/nicholastcs/alchemy/internal/environment"
@@ -26,13 +30,19 @@ import (
 
 var (
 	log *logrus.Entry
-	db  system.Db
+	env environment.Env
+
+	// config is the loaded config file, it is nil if the config file
+	// does not exist.
+	config    *core.ConfigManifest
+	configErr error
 
 	dump bool
 
 	cfgFile   string
 	logLevel  string
 	namespace string
+	sources   []string
 )
 
 var ascii = ` 
@@ -55,13 +65,38 @@ var rootCmd = &cobra.Command{
 	DisableSuggestions: false,
 	CompletionOptions:  cobra.CompletionOptions{DisableDefaultCmd: true},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		var err error
-		d, err := environment.New(log)
+		if configErr != nil {
+			return configErr
+		}
+
+		store, err := storage()
+		if err != nil {
+			return err
+		}
 
+		overrides, err := allowOverrides()
 		if err != nil {
 			return err
 		}
-		db = *d
+
+		e, err := environment.New(log, manifestSources(), store, overrides)
+		if err != nil {
+			return err
+		}
+		env = *e
+
+		if config != nil {
+			m, err := core.ConvertToAbstractedManifest(config)
+			if err != nil {
+				return err
+			}
+			m.Status.SetCondition(core.ResourceReady, true)
+
+			err = env.Set(*m)
+			if err != nil {
+				return err
+			}
+		}
 
 		return nil
 	},
@@ -70,15 +105,17 @@ var rootCmd = &cobra.Command{
 func Execute() error {
 	log = utils.NewLogger()
 
-	rootCmd.AddCommand(get.NewCommandV2(&db, log))
-	rootCmd.AddCommand(run.NewCommandV2(&db, log))
+	rootCmd.AddCommand(get.NewCommandV2(&env.Db, log))
+	rootCmd.AddCommand(run.NewCommandV2(&env.Db, env.Assets, log))
+	rootCmd.AddCommand(upgrade.NewCommand(&env.Db, env.Assets, log))
 	rootCmd.AddCommand(docs.NewCommand())
+	rootCmd.AddCommand(configcmd.NewCommand(log))
 
 	return rootCmd.Execute()
 }
 
 func init() {
-	cobra.OnInitialize(initLogLevel, initConfig)
+	cobra.OnInitialize(initConfig, initLogLevel)
 	cobra.OnFinalize(
 		func() {
 			if !dump {
@@ -110,32 +147,68 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "default", "namespace of manifest")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "text logging level")
 	rootCmd.PersistentFlags().BoolVarP(&dump, "dump", "d", false, "dump object to CHDIR after CLI completion (if applicable)")
+	rootCmd.PersistentFlags().StringArrayVar(&sources, "source", []string{}, "manifest source such as dir://<path>, git+<remote> or https://<url>, layered over the embedded manifests (can be repeated)")
 
 }
 
+// initConfig loads the config file from CLI flag --config, else from
+// `$HOME/.alchemy.yaml`. The selected config of the config file
+// supplies the defaults of namespace, log level and manifest sources.
 func initConfig() {
 	log.WithField("args", os.Args).Tracef("running with raw arguments '%s'", strings.Join(os.Args, " "))
 
-	// For now it will be disabled for this MVP
-	log.Warn("config file API is not loaded for now")
+	path := cfgFile
+	if path == "" {
+		var err error
+		path, err = environment.DefaultConfigPath()
+		if err != nil {
+			configErr = err
+			return
+		}
+	}
+
+	config, configErr = environment.LoadConfig(path)
+	if configErr != nil {
+		return
+	}
+	if config == nil {
+		if cfgFile != "" {
+			configErr = fmt.Errorf("config file '%s' not found", cfgFile)
+		}
+
+		log.Debugf("config file '%s' not found, skipping", path)
+		return
+	}
+
+	log.Debugf("config file '%s' loaded", path)
+
+	// an explicit CLI flag --log-level still logs despite the config.
+	if !config.Spec.LogsEnabled() && !rootCmd.PersistentFlags().Changed("log-level") {
+		log.Logger.SetOutput(io.Discard)
+	}
 
-	// dir, err := os.UserHomeDir()
-	// if err != nil {
-	// 	log.WithError(err).Panic("unable to determine home directory")
-	// }
-	// if cfgFile == "" {
-	// 	cfgFile = dir + "/.alchemy.yaml"
-	// }
+	selected := config.Selected()
+	if selected == nil {
+		return
+	}
 
-	// log.Debugf("the directory pointed to %s", cfgFile)
+	// CLI flag --namespace takes precedence over the selected config.
+	if !rootCmd.PersistentFlags().Changed("namespace") && selected.Namespace != "" {
+		namespace = selected.Namespace
+	}
 }
 
 func initLogLevel() {
 	// CLI flag --log-level would take the first precedence, else would
-	// take Environment Variable ALCHEMY_LOG_LEVEL for log level.
+	// take Environment Variable ALCHEMY_LOG_LEVEL, else would take the
+	// log level of the selected config for log level.
 	//
-	// If both are unset, it will be ultimately in ERROR log level.
-	l := lo.CoalesceOrEmpty(logLevel, os.Getenv("ALCHEMY_LOG_LEVEL"))
+	// If all are unset, it will be ultimately in ERROR log level.
+	var configLogLevel string
+	if config != nil && config.Selected() != nil {
+		configLogLevel = config.Selected().LogLevel
+	}
+	l := strings.ToUpper(lo.CoalesceOrEmpty(logLevel, os.Getenv("ALCHEMY_LOG_LEVEL"), configLogLevel))
 
 	switch l {
 	case "WARN", "WRN":
@@ -151,6 +224,85 @@ func initLogLevel() {
 	}
 }
 
+// manifestSources returns the manifest sources to be layered over the
+// embedded manifests.
+//
+// Sources from the selected config are loaded first, followed by the
+// sources from Environment Variable ALCHEMY_SOURCES (comma-separated),
+// then the sources from CLI flag --source. The latter source takes
+// precedence should the same resource exist in multiple sources, only if
+// overrides are allowed.
+func manifestSources() []string {
+	output := []string{}
+
+	if config != nil && config.Selected() != nil {
+		output = append(output, config.Selected().ManifestSources()...)
+	}
+
+	for _, source := range strings.Split(os.Getenv("ALCHEMY_SOURCES"), ",") {
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		output = append(output, strings.TrimSpace(source))
+	}
+
+	return append(output, sources...)
+}
+
+// allowOverrides returns whether the latter manifest source overrides the
+// same resource of the former sources by Environment Variable
+// ALCHEMY_ALLOW_OVERRIDES, else by the selected config.
+func allowOverrides() (bool, error) {
+	if v := os.Getenv("ALCHEMY_ALLOW_OVERRIDES"); v != "" {
+		allowed, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("invalid ALCHEMY_ALLOW_OVERRIDES '%s', it must be either true or false", v)
+		}
+
+		return allowed, nil
+	}
+
+	return config != nil && config.Selected() != nil && config.Selected().AllowOverrides, nil
+}
+
+// storage returns the persistent store of the environment by Environment
+// Variable ALCHEMY_STORAGE, else by the storage of the selected config.
+//
+// If both are unset, form results are persisted into the state directory,
+// where the retention of the selected config is applied if it is set.
+// It returns nil store for `memory` storage.
+func storage() (system.Store, error) {
+	var configStorage string
+	retention := system.DefaultRetention
+	if config != nil && config.Selected() != nil {
+		configStorage = config.Selected().Storage
+		if config.Selected().Retention > 0 {
+			retention = config.Selected().Retention
+		}
+	}
+	s := strings.ToLower(lo.CoalesceOrEmpty(os.Getenv("ALCHEMY_STORAGE"), configStorage, core.StorageFile))
+
+	switch s {
+	case core.StorageMemory:
+		return nil, nil
+	case core.StorageFile:
+		dir, err := system.DefaultStateDir()
+		if err != nil {
+			return nil, err
+		}
+
+		store, err := system.NewFileStore(dir, log)
+		if err != nil {
+			return nil, err
+		}
+
+		return store.WithRetention(retention), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage '%s', storage must be either '%s' or '%s'",
+			s, core.StorageFile, core.StorageMemory)
+	}
+}
+
 func dumpEnv() (dir string, err error) {
 	dirName := fmt.Sprintf("./alchemy-dump-%s", time.Now().Format(time.RFC3339))
 
@@ -161,7 +313,7 @@ func dumpEnv() (dir string, err error) {
 		return "", err
 	}
 
-	manifests, err := db.Dump()
+	manifests, err := env.Dump()
 	for _, manifest := range manifests {
 		c, err := experimentation.ToActualManifest[core.ManifestPattern](manifest)
 		if err != nil {
@@ -2,6 +2,13 @@ package run
 
 import (
 	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
 
 	"github.com/nicholastcs/alchemy/internal/apis/core"
 	"github.com/nicholastcs/alchemy/internal/apis/core/experimentation"
@@ -9,28 +16,51 @@ import (
 	"github.com/nicholastcs/alchemy/internal/formcreator"
 	"github.com/nicholastcs/alchemy/internal/generator"
 	"github.com/nicholastcs/alchemy/internal/system"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/samber/lo"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
-func NewCommandV2(db *system.Db, log *logrus.Entry) *cobra.Command {
+func NewCommandV2(db *system.Db, assets func(b core.Base) (fs.FS, error), log *logrus.Entry) *cobra.Command {
 	var (
 		codeTemplateName string
 		preview          bool
 		dir              string
+		valuesFile       string
+		setValues        []string
+		fromResult       string
+		dryRun           bool
+		diff             bool
+		policy           string
 	)
 
 	runCmd := &cobra.Command{
-		Use:           "run <form-name> -t|--codetemplate=<code-template-name>",
+		Use:           "run <form-name>|--from-result=<name|file> -t|--codetemplate=<code-template-name> [--values=<file>] [--set key=value]",
 		Short:         "To execute the user form to generate IAC from code templates.",
-		Args:          cobra.ExactArgs(1),
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		Aliases:       []string{"transmute"},
+		Example: `To fill the form 'app' then generate code from the code template 'k8s-deployment':
+  alchemy run app -t k8s-deployment
+
+To regenerate code from the answers of a previous run:
+  alchemy run --from-result form-app-20240101-120000 -t k8s-deployment`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("from-result") {
+				if len(args) > 0 {
+					return errors.New("form name cannot be set with --from-result, the form of the result is used instead")
+				}
+
+				return nil
+			}
+
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// flags and args
-			formName := args[0]
 			namespace, err := cmd.Flags().GetString("namespace")
 			if err != nil {
 				return err
@@ -40,6 +70,48 @@ func NewCommandV2(db *system.Db, log *logrus.Entry) *cobra.Command {
 				return err
 			}
 
+			// executor is made first, so that invalid flags are reported
+			// before filling the form.
+			g, err := generator.NewExecutor(log)
+			if err != nil {
+				return err
+			}
+			g, err = g.WithDefaultPolicy(policy)
+			if err != nil {
+				return err
+			}
+			g = g.WithAssets(assets)
+
+			// the terminal form is skipped whenever values are supplied
+			// so that it can be run in CI or scripts, where the existing
+			// file of prompt policy fails rather than waits on the
+			// terminal.
+			interactive := fromResult == "" && valuesFile == "" && len(setValues) == 0
+			if interactive {
+				g = g.WithPrompt(formcreator.ConfirmOverwrite)
+			}
+
+			// answers of the previous result are replayed against the
+			// form of the result, overridable by --values and --set.
+			var previousResult *v1alpha.FormResultManifest
+			var formName string
+			if fromResult != "" {
+				// the result is searched across namespaces unless
+				// --namespace is set, as the result is of the namespace
+				// of its form.
+				previousResult, err = loadFormResult(db, fromResult, namespace, !cmd.Flags().Changed("namespace"))
+				if err != nil {
+					return err
+				}
+
+				formName = previousResult.Spec.FormReference.Metadata.Name
+				if !cmd.Flags().Changed("namespace") {
+					namespace = previousResult.Spec.FormReference.Metadata.Namespace
+				}
+			} else {
+				formName = args[0]
+			}
+
 			// canonicalize aliases to actual apiversion & upKind
 			upApiVersion, upKind, err := experimentation.ToFormalApiVersionKind("forms")
 			if err != nil {
@@ -67,9 +139,27 @@ func NewCommandV2(db *system.Db, log *logrus.Entry) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			result, err := p.Run(*formManifestActual)
-			if err != nil {
-				return err
+
+			var result *v1alpha.FormResultManifest
+			if !interactive {
+				values, err := formcreator.LoadValues(valuesFile, setValues)
+				if err != nil {
+					return err
+				}
+
+				if previousResult != nil {
+					values = lo.Assign(previousResult.Spec.Result, values)
+				}
+
+				result, err = p.RunWithValues(*formManifestActual, values)
+				if err != nil {
+					return err
+				}
+			} else {
+				result, err = p.Run(*formManifestActual)
+				if err != nil {
+					return err
+				}
 			}
 
 			err = result.Spec.ConvertResultToNative()
@@ -77,35 +167,34 @@ func NewCommandV2(db *system.Db, log *logrus.Entry) *cobra.Command {
 				return err
 			}
 
-			// retrieve and store to environment db
-			resultAbstracted, err := core.ConvertToAbstractedManifest(result)
-			if err != nil {
-				return err
-			}
-			err = db.Set(*resultAbstracted)
+			// the answers of the secret fields are never displayed nor
+			// persisted.
+			redactedResult := result.Redacted()
+			resultAbstracted, err := core.ConvertToAbstractedManifest(&redactedResult)
 			if err != nil {
 				return err
 			}
 
 			// generate the code
-			g, err := generator.NewExecutor(log)
-			if err != nil {
-				return err
-			}
 			ctManifestActual, err := experimentation.Get[*v1alpha.CodeTemplateManifest](
 				db, ctApiVersion, ctKind, codeTemplateName, namespace)
 			if err != nil {
 				return err
 			}
+			if ctManifestActual == nil {
+				return errors.New("code template not found")
+			}
 
 			// TODO: do a dry run before allow form!
 			err = g.Generate(result, ctManifestActual)
 			if err != nil {
 				return err
 			}
+			result.Status.CodeTemplateReference = &ctManifestActual.Base
 
 			if preview {
-				ctManifest, err := core.ConvertToAbstractedManifest(ctManifestActual)
+				redactedCT := ctManifestActual.Redacted(result.Spec.Secrets())
+				ctManifest, err := core.ConvertToAbstractedManifest(&redactedCT)
 				if err != nil {
 					return err
 				}
@@ -114,31 +203,68 @@ func NewCommandV2(db *system.Db, log *logrus.Entry) *cobra.Command {
 				if err != nil {
 					return err
 				}
+			} else if dryRun || diff {
+				files, err := g.Diff(dir, &ctManifestActual.Status)
+				if err != nil {
+					return err
+				}
+
+				printDiff(files, dir, diff)
 			} else {
 				err = g.MakeFiles(dir, &ctManifestActual.Status)
 				if err != nil {
 					return err
 				}
+
+				for _, f := range ctManifestActual.Status.GeneratedCodeFiles {
+					if !slices.Contains([]string{v1alpha.FileCreated, v1alpha.FileOverwritten, v1alpha.FileAppended}, f.Action) {
+						continue
+					}
+					result.Status.GeneratedFiles = append(result.Status.GeneratedFiles, filepath.Join(dir, f.File))
+				}
+				for _, f := range ctManifestActual.Status.PatchedFiles {
+					patched := filepath.Join(dir, f.File)
+					if f.Action != v1alpha.FilePatched || slices.Contains(result.Status.GeneratedFiles, patched) {
+						continue
+					}
+					result.Status.GeneratedFiles = append(result.Status.GeneratedFiles, patched)
+				}
+
+				lock, err := v1alpha.NewLock(utils.Version(), *formManifestActual, *result, *ctManifestActual)
+				if err != nil {
+					return err
+				}
+
+				err = g.WriteLock(dir, lock)
+				if err != nil {
+					return err
+				}
 			}
 
 			// persist everything into environment for
 			// troubleshooting, whereas it can be dumped (using
-			// --dump flag) for analysis.
-			ctManifest, err := core.ConvertToAbstractedManifest(ctManifestActual)
+			// --dump flag) for analysis, whereas the secrets are
+			// redacted.
+			redactedCT := ctManifestActual.Redacted(result.Spec.Secrets())
+			ctManifest, err := core.ConvertToAbstractedManifest(&redactedCT)
 			if err != nil {
 				return err
 			}
+			manifests := []core.AbstractedManifest{*ctManifest}
 
-			resultAbstracted, err = core.ConvertToAbstractedManifest(result)
-			if err != nil {
-				return err
+			// the form result is only saved once the files are
+			// written, so that the preview, dry run and diff never
+			// push the applied results out of retention.
+			if !preview && !dryRun && !diff {
+				redactedResult = result.Redacted()
+				resultAbstracted, err = core.ConvertToAbstractedManifest(&redactedResult)
+				if err != nil {
+					return err
+				}
+				manifests = append(manifests, *resultAbstracted)
 			}
 
-			err = db.SetAll(
-				[]core.AbstractedManifest{
-					*ctManifest, *resultAbstracted,
-				},
-			)
+			err = db.SetAll(manifests)
 			if err != nil {
 				return err
 			}
@@ -150,7 +276,139 @@ func NewCommandV2(db *system.Db, log *logrus.Entry) *cobra.Command {
 	runCmd.Flags().StringVarP(&codeTemplateName, "codetemplate", "t", "", "display mode for the resource")
 	runCmd.Flags().BoolVarP(&preview, "preview", "p", false, "preview the outcome in YAML form only")
 	runCmd.Flags().StringVar(&dir, "dir", "./", "directory of the code generated")
+	runCmd.Flags().StringVarP(&valuesFile, "values", "f", "", "YAML file of form values, skipping the interactive form")
+	runCmd.Flags().StringArrayVar(&setValues, "set", []string{}, "form value in key=value form, skipping the interactive form (can be repeated)")
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "summarise the changes of files under --dir without touching the filesystem")
+	runCmd.Flags().BoolVar(&diff, "diff", false, "print the unified diff of files under --dir without touching the filesystem, implies --dry-run")
+	runCmd.Flags().StringVar(&policy, "policy", v1alpha.OverwritePolicy,
+		fmt.Sprintf("policy of existing files, for files without policy of their own, either %s", strings.Join(v1alpha.FilePolicies, ", ")))
+	runCmd.Flags().StringVar(&fromResult, "from-result", "", "name of the stored form result, or path of its YAML file (e.g. from --dump), to replay its answers skipping the interactive form")
 	runCmd.MarkFlagRequired("codetemplate")
 
 	return runCmd
 }
+
+// printDiff prints the summary of the changes of files, along with their
+// unified diff if withDiff is true.
+func printDiff(files []generator.FileDiff, dir string, withDiff bool) {
+	if withDiff {
+		for _, f := range files {
+			if f.UnifiedDiff == "" {
+				continue
+			}
+
+			utils.PrintUnifiedDiff(f.UnifiedDiff)
+			fmt.Println()
+		}
+	}
+
+	counts := lo.CountValuesBy(files, func(f generator.FileDiff) string {
+		return f.Action
+	})
+
+	contents := [][]string{}
+	for _, f := range files {
+		mode := fmt.Sprintf("%04o", f.Mode)
+		switch {
+		case f.Action == generator.DiffDeleted:
+			mode = fmt.Sprintf("%04o", f.CurrentMode)
+		case f.Action != generator.DiffCreated && f.CurrentMode != f.Mode:
+			mode = fmt.Sprintf("%04o → %04o", f.CurrentMode, f.Mode)
+		}
+
+		contents = append(contents, []string{f.Path, f.Action, mode})
+	}
+	utils.PrintTableV2([]string{"file", "action", "mode"}, contents,
+		fmt.Sprintf(" * %d file(s) in '%s'", len(files), dir))
+
+	utils.Tell("Dry run", fmt.Sprintf("%d created, %d modified, %d deleted and %d unchanged in '%s', nothing is written.",
+		counts[generator.DiffCreated], counts[generator.DiffModified],
+		counts[generator.DiffDeleted], counts[generator.DiffUnchanged], dir))
+}
+
+// loadFormResult loads the form result from the YAML file if the file
+// exists, else from the environment database by its name, which is searched
+// across every namespace if anyNamespace is true.
+func loadFormResult(db *system.Db, nameOrFile string, namespace string, anyNamespace bool) (*v1alpha.FormResultManifest, error) {
+	apiVersion, kind, err := experimentation.ToFormalApiVersionKind("formresults")
+	if err != nil {
+		return nil, err
+	}
+
+	fs := afero.NewOsFs()
+
+	isFile, err := afero.Exists(fs, nameOrFile)
+	if err != nil {
+		return nil, err
+	}
+	if !isFile && anyNamespace {
+		return findFormResult(db, apiVersion, kind, nameOrFile)
+	}
+	if !isFile {
+		result, err := experimentation.Get[*v1alpha.FormResultManifest](
+			db, apiVersion, kind, nameOrFile, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			return nil, fmt.Errorf("form result '%s' not found under namespace '%s'", nameOrFile, namespace)
+		}
+
+		return result, nil
+	}
+
+	in, err := afero.ReadFile(fs, nameOrFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read form result file '%s': %w", nameOrFile, err)
+	}
+
+	var m core.AbstractedManifest
+	err = yaml.Unmarshal(in, &m)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse form result file '%s': %w", nameOrFile, err)
+	}
+	if m.APIVersion != apiVersion || m.Kind != kind {
+		return nil, fmt.Errorf("file '%s' must be a %s of %s, found %s of %s",
+			nameOrFile, kind, apiVersion, m.Kind, m.APIVersion)
+	}
+
+	result, err := experimentation.ToActualManifest[*v1alpha.FormResultManifest](m)
+	if err != nil {
+		return nil, err
+	}
+
+	err = result.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid form result file '%s': %w", nameOrFile, err)
+	}
+
+	return result, nil
+}
+
+// findFormResult finds the form result by its name across every namespace,
+// it fails if the name is found under more than one namespace.
+func findFormResult(db *system.Db, apiVersion, kind, name string) (*v1alpha.FormResultManifest, error) {
+	manifests, err := db.GetByGVK(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	found := lo.Filter(manifests, func(m core.AbstractedManifest, _ int) bool {
+		return m.Metadata.Name == name
+	})
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("form result '%s' not found under any namespace", name)
+	case 1:
+		return experimentation.ToActualManifest[*v1alpha.FormResultManifest](found[0])
+	default:
+		namespaces := lo.Map(found, func(m core.AbstractedManifest, _ int) string {
+			return m.Metadata.Namespace
+		})
+		slices.Sort(namespaces)
+
+		return nil, fmt.Errorf("form result '%s' is found under namespaces '%s', set --namespace to pick one",
+			name, strings.Join(namespaces, "', '"))
+	}
+}
@@ -0,0 +1,53 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/system"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFormResultAcrossNamespaces(t *testing.T) {
+	db, err := system.NewLocalDB(utils.NewLogger())
+	require.NoError(t, err)
+
+	setResult := func(name, namespace string) {
+		form := core.Base{
+			APIVersion: "alchemy.io/v1alpha",
+			Kind:       "Form",
+			Metadata:   core.Metadata{Name: "k8s-deployment", Namespace: namespace},
+		}
+
+		result, err := v1alpha.NewFormResult(name, namespace, form, map[string]any{"name": "app"})
+		require.NoError(t, err)
+
+		m, err := core.ConvertToAbstractedManifest(result)
+		require.NoError(t, err)
+		require.NoError(t, db.Set(*m))
+	}
+	setResult("form-k8s-deployment-1", "k8s.io")
+	setResult("form-shared", "k8s.io")
+	setResult("form-shared", "terraform")
+
+	// the result of the namespaced form is found without --namespace
+	result, err := loadFormResult(db, "form-k8s-deployment-1", "default", true)
+	require.NoError(t, err)
+	assert.Equal(t, "k8s.io", result.Spec.FormReference.Metadata.Namespace)
+
+	_, err = loadFormResult(db, "form-k8s-deployment-1", "default", false)
+	assert.ErrorContains(t, err, "not found under namespace 'default'")
+
+	_, err = loadFormResult(db, "form-shared", "default", true)
+	assert.ErrorContains(t, err, "is found under namespaces 'k8s.io', 'terraform'")
+
+	result, err = loadFormResult(db, "form-shared", "terraform", false)
+	require.NoError(t, err)
+	assert.Equal(t, "terraform", result.Metadata.Namespace)
+
+	_, err = loadFormResult(db, "unknown", "default", true)
+	assert.ErrorContains(t, err, "not found under any namespace")
+}
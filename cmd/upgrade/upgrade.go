@@ -0,0 +1,216 @@
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/core/experimentation"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/formcreator"
+	"github.com/nicholastcs/alchemy/internal/generator"
+	"github.com/nicholastcs/alchemy/internal/system"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	conflictMarkers string = "markers"
+	conflictReport  string = "report"
+)
+
+func NewCommand(db *system.Db, assets func(b core.Base) (fs.FS, error), log *logrus.Entry) *cobra.Command {
+	var (
+		codeTemplateName string
+		dir              string
+		valuesFile       string
+		setValues        []string
+		conflict         string
+	)
+
+	upgradeCmd := &cobra.Command{
+		Use:           "upgrade [--dir=<dir>] [-t|--codetemplate=<code-template-name>] [--conflict=markers|report]",
+		Short:         "To upgrade the generated code to the latest code template, retaining the local edits.",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Example: `To upgrade the code generated into the current directory:
+  alchemy upgrade
+
+To upgrade onto another code template, answering the new field 'port':
+  alchemy upgrade --dir ./app -t k8s-deployment-v2 --set port=8080
+
+To report the conflicts without writing any file:
+  alchemy upgrade --conflict report`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if conflict != conflictMarkers && conflict != conflictReport {
+				return fmt.Errorf("--conflict must be either '%s' or '%s'", conflictMarkers, conflictReport)
+			}
+
+			lock, err := generator.ReadLock(dir)
+			if err != nil {
+				return err
+			}
+
+			// the form and code template are located by the lock,
+			// unless they are overridden.
+			namespace := lock.Spec.FormReference.Namespace
+			if cmd.Flags().Changed("namespace") {
+				namespace, err = cmd.Flags().GetString("namespace")
+				if err != nil {
+					return err
+				}
+			}
+			if codeTemplateName == "" {
+				codeTemplateName = lock.Spec.CodeTemplateReference.Name
+			}
+
+			upApiVersion, upKind, err := experimentation.ToFormalApiVersionKind("forms")
+			if err != nil {
+				return err
+			}
+			ctApiVersion, ctKind, err := experimentation.ToFormalApiVersionKind("codetemplates")
+			if err != nil {
+				return err
+			}
+
+			formManifestActual, err := experimentation.Get[*v1alpha.FormManifest](
+				db, upApiVersion, upKind, lock.Spec.FormReference.Name, namespace)
+			if err != nil {
+				return err
+			}
+			if formManifestActual == nil {
+				return fmt.Errorf("form '%s' not found under namespace '%s'", lock.Spec.FormReference.Name, namespace)
+			}
+			if formManifestActual.Status.HasErr() {
+				return formManifestActual.Status.ToNativeErr()
+			}
+
+			ctManifestActual, err := experimentation.Get[*v1alpha.CodeTemplateManifest](
+				db, ctApiVersion, ctKind, codeTemplateName, namespace)
+			if err != nil {
+				return err
+			}
+			if ctManifestActual == nil {
+				return fmt.Errorf("code template '%s' not found under namespace '%s'", codeTemplateName, namespace)
+			}
+
+			// the locked answers are re-validated against the form, as
+			// the form could be changed since.
+			values, err := formcreator.LoadValues(valuesFile, setValues)
+			if err != nil {
+				return err
+			}
+
+			p, err := formcreator.NewFormCreatorV1Alpha(log)
+			if err != nil {
+				return err
+			}
+			result, err := p.RunWithValues(*formManifestActual, lo.Assign(lock.Spec.Result, values))
+			if err != nil {
+				return err
+			}
+			err = result.Spec.ConvertResultToNative()
+			if err != nil {
+				return err
+			}
+
+			g, err := generator.NewExecutor(log)
+			if err != nil {
+				return err
+			}
+			g = g.WithAssets(assets)
+
+			err = g.Generate(result, ctManifestActual)
+			if err != nil {
+				return err
+			}
+			result.Status.CodeTemplateReference = &ctManifestActual.Base
+
+			files, err := g.PlanUpgrade(dir, lock, &ctManifestActual.Status)
+			if err != nil {
+				return err
+			}
+
+			contents := [][]string{}
+			for _, f := range files {
+				contents = append(contents, []string{f.Path, f.Action, fmt.Sprintf("%d", f.Conflicts)})
+			}
+			utils.PrintTableV2([]string{"file", "action", "conflicts"}, contents,
+				fmt.Sprintf(" * %d file(s) planned to upgrade in '%s'", len(files), dir))
+
+			conflictsErr := generator.Conflicts(files)
+			if conflictsErr != nil && conflict == conflictReport {
+				return errors.Join(errors.New("upgrade aborted without writing any file"), conflictsErr)
+			}
+
+			err = g.ApplyUpgrade(dir, files)
+			if err != nil {
+				return err
+			}
+
+			// the upgraded generation becomes the base of the next
+			// upgrade.
+			newLock, err := v1alpha.NewLock(utils.Version(), *formManifestActual, *result, *ctManifestActual)
+			if err != nil {
+				return err
+			}
+			err = g.WriteLock(dir, newLock)
+			if err != nil {
+				return err
+			}
+
+			for _, f := range newLock.Spec.Files {
+				result.Status.GeneratedFiles = append(result.Status.GeneratedFiles, filepath.Join(dir, f.Path))
+			}
+			for _, f := range ctManifestActual.Status.PatchedFiles {
+				patched := filepath.Join(dir, f.File)
+				if f.Action != v1alpha.FilePatched || slices.Contains(result.Status.GeneratedFiles, patched) {
+					continue
+				}
+				result.Status.GeneratedFiles = append(result.Status.GeneratedFiles, patched)
+			}
+
+			// the secrets are never persisted.
+			redactedResult := result.Redacted()
+			resultAbstracted, err := core.ConvertToAbstractedManifest(&redactedResult)
+			if err != nil {
+				return err
+			}
+			redactedCT := ctManifestActual.Redacted(result.Spec.Secrets())
+			ctManifest, err := core.ConvertToAbstractedManifest(&redactedCT)
+			if err != nil {
+				return err
+			}
+			err = db.SetAll([]core.AbstractedManifest{*ctManifest, *resultAbstracted})
+			if err != nil {
+				return err
+			}
+
+			if conflictsErr != nil {
+				utils.Warning("Conflicts found",
+					fmt.Sprintf("Resolve the conflict markers of the following file(s):\n%s", conflictsErr))
+
+				return nil
+			}
+
+			utils.Tell("✨ Code Upgraded", fmt.Sprintf("Alchemy has upgraded code in '%s' without any conflict.", dir))
+
+			return nil
+		},
+	}
+
+	upgradeCmd.Flags().StringVarP(&codeTemplateName, "codetemplate", "t", "", "code template to upgrade onto (default is the locked code template)")
+	upgradeCmd.Flags().StringVar(&dir, "dir", "./", "directory of the code generated")
+	upgradeCmd.Flags().StringVarP(&valuesFile, "values", "f", "", "YAML file of form values, overriding the locked answers")
+	upgradeCmd.Flags().StringArrayVar(&setValues, "set", []string{}, "form value in key=value form, overriding the locked answers (can be repeated)")
+	upgradeCmd.Flags().StringVar(&conflict, "conflict", conflictMarkers, "either write conflict markers into files (markers), or report conflicts without writing any file (report)")
+
+	return upgradeCmd
+}
@@ -1,25 +1,128 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dustin/go-humanize/english"
+)
+
+// ConfigDirectoryType is the type of sub config that layers the manifests
+// from the directory.
+const ConfigDirectoryType string = "directory"
+
+var configLogLevels = []string{"ERROR", "WARN", "INFO", "DEBUG", "TRACE"}
+
+const (
+	// StorageFile persists form results into the state directory.
+	StorageFile string = "file"
+
+	// StorageMemory keeps everything in memory, which vanish at process
+	// exit.
+	StorageMemory string = "memory"
 )
 
+var storages = []string{StorageFile, StorageMemory}
+
 type ConfigManifest struct {
 	Base   `yaml:",inline" mapstructure:",squash"`
 	Spec   ConfigSpec `mapstructure:"spec" yaml:"spec" json:"spec"`
-	Status Status     `yaml:"status,omitempty" mapstructure:"status.omitempty" json:"status"`
+	Status Status     `yaml:"status,omitempty" mapstructure:"status,omitempty" json:"status"`
 }
 
 type ConfigSpec struct {
-	EnableLogs     bool        `mapstructure:"enableLogs" yaml:"enableLogs" json:"enableLogs"`
+	// EnableLogs enables logging into `./alchemy.log`, where unset is
+	// enabled.
+	EnableLogs     *bool       `mapstructure:"enableLogs" yaml:"enableLogs,omitempty" json:"enableLogs,omitempty"`
 	SelectedConfig string      `mapstructure:"selectedConfig" yaml:"selectedConfig" json:"selectedConfig"`
 	Configs        []SubConfig `mapstructure:"configs" yaml:"configs" json:"configs"`
 }
 
 type SubConfig struct {
 	Name      string `mapstructure:"name" yaml:"name" json:"name"`
-	Type      string `mapstructure:"type" yaml:"type" json:"type"`
-	Directory string `mapstructure:"directory" yaml:"directory" json:"directory"`
+	Type      string `mapstructure:"type" yaml:"type,omitempty" json:"type,omitempty"`
+	Directory string `mapstructure:"directory" yaml:"directory,omitempty" json:"directory,omitempty"`
+
+	// Sources are the manifest sources layered over the embedded
+	// manifests, such as `dir://<path>` or `git+<remote>`.
+	Sources []string `mapstructure:"sources" yaml:"sources,omitempty" json:"sources,omitempty"`
+
+	// AllowOverrides lets the resource of the latter manifest source
+	// override the same resource of the former sources when
+	// `ALCHEMY_ALLOW_OVERRIDES` is unset, otherwise it is a conflict.
+	AllowOverrides bool `mapstructure:"allowOverrides" yaml:"allowOverrides,omitempty" json:"allowOverrides,omitempty"`
+
+	// Namespace is the default namespace when `--namespace` is unset.
+	Namespace string `mapstructure:"namespace" yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// LogLevel is the log level when both `--log-level` and
+	// `ALCHEMY_LOG_LEVEL` are unset.
+	LogLevel string `mapstructure:"logLevel" yaml:"logLevel,omitempty" json:"logLevel,omitempty"`
+
+	// Storage is the storage backend of the environment when
+	// `ALCHEMY_STORAGE` is unset, either `file` or `memory`.
+	Storage string `mapstructure:"storage" yaml:"storage,omitempty" json:"storage,omitempty"`
+
+	// Retention is the number of form results kept by the `file` storage,
+	// where the oldest ones are pruned beyond it. Unset keeps the default
+	// retention of the storage.
+	Retention int `mapstructure:"retention" yaml:"retention,omitempty" json:"retention,omitempty"`
+}
+
+func (c *ConfigManifest) Validate() error {
+	var errs error
+	errs = errors.Join(errs, c.Base.Validate())
+	errs = errors.Join(errs, validateConfigSpec(c.Spec))
+
+	return errs
+}
+
+func validateConfigSpec(spec ConfigSpec) error {
+	var errs error
+
+	names := map[string]bool{}
+	for i, cfg := range spec.Configs {
+		path := fmt.Sprintf("spec.configs[%d]", i)
+
+		if cfg.Name == "" {
+			errs = errors.Join(errs, NewPathError(path+".name", errors.New("config name cannot be empty")))
+		}
+		if names[cfg.Name] {
+			errs = errors.Join(errs, NewPathError(path+".name", fmt.Errorf("duplicate config name '%s'", cfg.Name)))
+		}
+		names[cfg.Name] = true
+
+		if cfg.Type != "" && cfg.Type != ConfigDirectoryType {
+			errs = errors.Join(errs, NewPathError(path+".type",
+				fmt.Errorf("config type must be '%s' if it is set", ConfigDirectoryType)))
+		}
+		if cfg.Type == ConfigDirectoryType && cfg.Directory == "" {
+			errs = errors.Join(errs, NewPathError(path+".directory",
+				fmt.Errorf("directory cannot be empty for config type '%s'", ConfigDirectoryType)))
+		}
+
+		if cfg.LogLevel != "" && !slices.Contains(configLogLevels, strings.ToUpper(cfg.LogLevel)) {
+			errs = errors.Join(errs, NewPathError(path+".logLevel",
+				fmt.Errorf("log level must be either %s", english.OxfordWordSeries(configLogLevels, "or"))))
+		}
+
+		if cfg.Storage != "" && !slices.Contains(storages, cfg.Storage) {
+			errs = errors.Join(errs, NewPathError(path+".storage",
+				fmt.Errorf("storage must be either %s", english.OxfordWordSeries(storages, "or"))))
+		}
+		if cfg.Retention < 0 {
+			errs = errors.Join(errs, NewPathError(path+".retention", errors.New("retention cannot be negative")))
+		}
+	}
+
+	if spec.SelectedConfig != "" && !names[spec.SelectedConfig] {
+		errs = errors.Join(errs, NewPathError("spec.selectedConfig",
+			fmt.Errorf("config '%s' not found in spec.configs.*.name", spec.SelectedConfig)))
+	}
+
+	return errs
 }
 
 func (c *ConfigManifest) SelectConfig(configName string) error {
@@ -32,3 +135,33 @@ func (c *ConfigManifest) SelectConfig(configName string) error {
 
 	return fmt.Errorf("config '%s' not found in spec.configs.*.name", configName)
 }
+
+// Selected returns the selected sub config, it returns nil if none of the
+// sub config is selected.
+func (c *ConfigManifest) Selected() *SubConfig {
+	for i, cfg := range c.Spec.Configs {
+		if cfg.Name == c.Spec.SelectedConfig {
+			return &c.Spec.Configs[i]
+		}
+	}
+
+	return nil
+}
+
+// ManifestSources returns the manifest sources of the sub config, where
+// the directory of `directory` typed sub config is the first source.
+func (s *SubConfig) ManifestSources() []string {
+	output := []string{}
+
+	if s.Type == ConfigDirectoryType && s.Directory != "" {
+		output = append(output, "dir://"+s.Directory)
+	}
+
+	return append(output, s.Sources...)
+}
+
+// LogsEnabled returns whether logging is enabled, which is enabled
+// unless `enableLogs` is explicitly set to false.
+func (s ConfigSpec) LogsEnabled() bool {
+	return s.EnableLogs == nil || *s.EnableLogs
+}
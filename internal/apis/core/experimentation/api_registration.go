@@ -22,6 +22,38 @@ func init() {
 			"apis", "api",
 		},
 	)
+	registerAPI(
+		"alchemy.io/core/readonly",
+		"Source",
+		[]string{
+			"order", "name", "provider", "uri", "resources", "overrides",
+		},
+		[]string{
+			"spec.order", "metadata.name", "spec.provider", "spec.uri", "spec.resources.size()", "spec.overrides",
+		},
+		func() core.ManifestPattern {
+			return &core.SourceManifest{}
+		},
+		[]string{
+			"sources", "source",
+		},
+	)
+	registerAPI(
+		"alchemy.io/core",
+		"Config",
+		[]string{
+			"name", "selected-config", "enable-logs", "total-configs",
+		},
+		[]string{
+			"metadata.name", "spec.selectedConfig", "has(spec.enableLogs) ? spec.enableLogs : true", "spec.configs.size()",
+		},
+		func() core.ManifestPattern {
+			return &core.ConfigManifest{}
+		},
+		[]string{
+			"configs", "config",
+		},
+	)
 	registerAPI(
 		"alchemy.io/v1alpha",
 		"Form",
@@ -41,8 +73,14 @@ func init() {
 	registerAPI(
 		"alchemy.io/v1alpha/internal",
 		"FormResult",
-		[]string{},
-		[]string{},
+		[]string{
+			"namespace", "name", "form", "code-template", "files",
+		},
+		[]string{
+			"metadata.namespace", "metadata.name", "spec.formReference.metadata.name",
+			"has(status.codeTemplateReference) ? status.codeTemplateReference.metadata.name : ''",
+			"has(status.generatedFiles) ? status.generatedFiles.size() : 0",
+		},
 		func() core.ManifestPattern {
 			return &v1alpha.FormResultManifest{}
 		},
@@ -53,6 +91,24 @@ func init() {
 			"results",
 		},
 	)
+	registerAPI(
+		"alchemy.io/v1alpha/internal",
+		"Lock",
+		[]string{
+			"namespace", "name", "form", "code-template", "alchemy-version", "files",
+		},
+		[]string{
+			"metadata.namespace", "metadata.name", "spec.formReference.name",
+			"spec.codeTemplateReference.name", "spec.alchemyVersion", "spec.files.size()",
+		},
+		func() core.ManifestPattern {
+			return &v1alpha.LockManifest{}
+		},
+		[]string{
+			"locks",
+			"lock",
+		},
+	)
 	registerAPI(
 		"alchemy.io/v1alpha",
 		"CodeTemplate",
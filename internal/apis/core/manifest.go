@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/go-viper/mapstructure/v2"
 )
@@ -120,3 +121,43 @@ func (b *Base) GetFilePath() string {
 
 	return b.Metadata.Annotations["alchemy.io/filepath"]
 }
+
+func (b *Base) SetSource(uri string) {
+	if b.Metadata.Annotations == nil {
+		b.Metadata.Annotations = map[string]string{}
+	}
+
+	b.Metadata.Annotations["alchemy.io/source"] = uri
+}
+
+func (b *Base) GetSource() string {
+	if b.Metadata.Annotations == nil {
+		return "N/A"
+	}
+
+	if b.Metadata.Annotations["alchemy.io/source"] == "" {
+		return "N/A"
+	}
+
+	return b.Metadata.Annotations["alchemy.io/source"]
+}
+
+func (b *Base) SetDocumentIndex(index int) {
+	if b.Metadata.Annotations == nil {
+		b.Metadata.Annotations = map[string]string{}
+	}
+
+	b.Metadata.Annotations["alchemy.io/document-index"] = strconv.Itoa(index)
+}
+
+func (b *Base) GetDocumentIndex() string {
+	if b.Metadata.Annotations == nil {
+		return "N/A"
+	}
+
+	if b.Metadata.Annotations["alchemy.io/document-index"] == "" {
+		return "N/A"
+	}
+
+	return b.Metadata.Annotations["alchemy.io/document-index"]
+}
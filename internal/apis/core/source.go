@@ -0,0 +1,22 @@
+package core
+
+// SourceManifest is a readonly manifest that reports the manifest source
+// loaded into the environment.
+type SourceManifest struct {
+	Base   `yaml:",inline" mapstructure:",squash"`
+	Spec   SourceSpec `mapstructure:"spec" yaml:"spec" json:"spec"`
+	Status Status     `yaml:"status" mapstructure:"status" json:"status"`
+}
+
+type SourceSpec struct {
+	// Order is the order of the source being loaded, the source with
+	// greater order takes precedence.
+	Order     int      `yaml:"order" mapstructure:"order" json:"order"`
+	URI       string   `yaml:"uri" mapstructure:"uri" json:"uri"`
+	Provider  string   `yaml:"provider" mapstructure:"provider" json:"provider"`
+	Resources []string `yaml:"resources" mapstructure:"resources" json:"resources"`
+
+	// Overrides are the resources of the source that override the same
+	// resource from the sources loaded earlier.
+	Overrides []string `yaml:"overrides,omitempty" mapstructure:"overrides" json:"overrides,omitempty"`
+}
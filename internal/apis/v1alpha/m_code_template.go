@@ -1,8 +1,18 @@
 package v1alpha
 
 import (
+	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/dustin/go-humanize/english"
 	"github.com/nicholastcs/alchemy/internal/apis/core"
@@ -15,12 +25,63 @@ var (
 			"funcs=sprig",
 			"missingkey=error",
 		},
+		"jinja": {
+			"trim_blocks",
+			"lstrip_blocks",
+			"autoescape",
+		},
+		"envsubst": {
+			"missingkey=error",
+			"env",
+		},
+		"cel-yaml": {
+			"output=json",
+		},
 	}
 )
 
 const CodeTemplateConsumptionReady string = "CodeTemplateConsumptionReady"
 const CodeTemplateConsumptionDone string = "CodeTemplateConsumptionDone"
 
+// CodeTemplateFilesCommitted reports whether the generated files are
+// committed into the directory, it is false if they are rolled back.
+const CodeTemplateFilesCommitted string = "CodeTemplateFilesCommitted"
+
+// policies of the generated file when the file already exists.
+const (
+	OverwritePolicy    string = "overwrite"
+	SkipIfExistsPolicy string = "skip-if-exists"
+	FailIfExistsPolicy string = "fail-if-exists"
+	AppendPolicy       string = "append"
+	PromptPolicy       string = "prompt"
+)
+
+var FilePolicies = []string{OverwritePolicy, SkipIfExistsPolicy, FailIfExistsPolicy, AppendPolicy, PromptPolicy}
+
+// choices of the generated file when its generated code is blank.
+const (
+	OnEmptyDelete string = "delete"
+	OnEmptyKeep   string = "keep"
+)
+
+var onEmptyChoices = []string{OnEmptyDelete, OnEmptyKeep}
+
+// actions taken on the generated file, which are reported in the status.
+const (
+	FileCreated     string = "created"
+	FileOverwritten string = "overwritten"
+	FileAppended    string = "appended"
+	FileSkipped     string = "skipped"
+	FileDeleted     string = "deleted"
+	FileKept        string = "kept"
+	FilePatched     string = "patched"
+	FileUnchanged   string = "unchanged"
+
+	// FileApplied is the patch applied already by the previous generation
+	// recorded in the lock file, which is not applied again.
+	FileApplied string = "applied"
+)
+
 type CodeTemplateManifest struct {
 	core.Base `yaml:",inline" mapstructure:",squash"`
 	Spec      CodeTemplateSpec   `mapstructure:"spec" yaml:"spec" json:"spec"`
@@ -31,21 +92,203 @@ type CodeTemplateSpec struct {
 	Kind          string         `mapstructure:"kind" yaml:"kind" json:"kind"`
 	Options       []string       `mapstructure:"options" yaml:"options" json:"options"`
 	GenerateFiles []GenerateFile `mapstructure:"generateFiles" yaml:"generateFiles" json:"generateFiles"`
+
+	// CopyFiles are the files (or directories) copied from the manifest
+	// source, which are located relative to the manifest file.
+	CopyFiles []CopyFile `mapstructure:"copyFiles" yaml:"copyFiles,omitempty" json:"copyFiles,omitempty"`
+
+	// PatchFiles are the patches applied on the existing files, such as
+	// adding a service to `docker-compose.yaml`.
+	PatchFiles []PatchFile `mapstructure:"patchFiles" yaml:"patchFiles,omitempty" json:"patchFiles,omitempty"`
 }
 
 type GenerateFile struct {
 	File     string `mapstructure:"file" yaml:"file" json:"file"`
 	Template string `mapstructure:"template" yaml:"template" json:"template"`
+
+	// Policy is the policy when the file already exists, it falls back to
+	// the policy of CLI flag `--policy` if it is unset.
+	Policy string `mapstructure:"policy" yaml:"policy,omitempty" json:"policy,omitempty"`
+
+	// OnEmpty is either to delete (default) or keep the existing file
+	// when the generated code is blank.
+	OnEmpty string `mapstructure:"onEmpty" yaml:"onEmpty,omitempty" json:"onEmpty,omitempty"`
+
+	// Mode is the octal file mode of the file, such as `0755` for
+	// scripts. If unset, the existing file retains its mode, whereas the
+	// new file is made with mode `0644`.
+	Mode string `mapstructure:"mode" yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// When is the CEL expression over the answers of the form (`result`),
+	// the file is generated blank if it evaluates to false, thus the
+	// existing file is deleted unless onEmpty is `keep`.
+	When string `mapstructure:"when" yaml:"when,omitempty" json:"when,omitempty"`
+
+	// ForEach is the CEL expression of list over the answers of the form
+	// (`result`), such as `result.regions`. The file is generated for
+	// every element, where both the file path and the template refer the
+	// element by the name of As.
+	ForEach string `mapstructure:"forEach" yaml:"forEach,omitempty" json:"forEach,omitempty"`
+
+	// As is the name of the element of ForEach, defaults to `item`.
+	As string `mapstructure:"as" yaml:"as,omitempty" json:"as,omitempty"`
+}
+
+// DefaultForEachAs is the name of the element of ForEach if As is unset.
+const DefaultForEachAs string = "item"
+
+var identifierRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// CopyFile copies the file, or every file of the directory, from the
+// manifest source into the output directory as is, unless it is templated.
+type CopyFile struct {
+	// Source is the file or directory relative to the directory of the
+	// manifest file.
+	Source string `mapstructure:"source" yaml:"source" json:"source"`
+
+	// Destination is the file or directory relative to the output
+	// directory, it defaults to the source.
+	Destination string `mapstructure:"destination" yaml:"destination,omitempty" json:"destination,omitempty"`
+
+	// Include and Exclude are the glob patterns of files of the source
+	// directory, where exclude takes precedence over include. Pattern
+	// without `/` matches the file name, otherwise it matches the path
+	// relative to the source directory.
+	Include []string `mapstructure:"include" yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude []string `mapstructure:"exclude" yaml:"exclude,omitempty" json:"exclude,omitempty"`
+
+	// Template renders every copied file as template, whereas binary file
+	// is always copied as is.
+	Template bool `mapstructure:"template" yaml:"template,omitempty" json:"template,omitempty"`
+
+	Policy string `mapstructure:"policy" yaml:"policy,omitempty" json:"policy,omitempty"`
+	Mode   string `mapstructure:"mode" yaml:"mode,omitempty" json:"mode,omitempty"`
+}
+
+// types of the patch of the existing file.
+const (
+	JSONPatchType   string = "json-patch"
+	YAMLMergeType   string = "yaml-merge"
+	InsertPatchType string = "insert"
+)
+
+var patchTypes = []string{JSONPatchType, YAMLMergeType, InsertPatchType}
+
+// PatchFile patches the existing file rather than overwriting it, where the
+// patch is rendered by the templating kind.
+type PatchFile struct {
+	// File is the existing file relative to the output directory, which
+	// is templated as the generated file.
+	File string `mapstructure:"file" yaml:"file" json:"file"`
+
+	// Type is either `json-patch` (RFC 6902 operations on YAML or JSON
+	// file), `yaml-merge` (YAML document merged into YAML or JSON file) or
+	// `insert` (text inserted into the file).
+	Type string `mapstructure:"type" yaml:"type" json:"type"`
+
+	// Template is the template of the patch, which is the list of
+	// operations, the document to be merged or the text to be inserted by
+	// the type.
+	Template string `mapstructure:"template" yaml:"template" json:"template"`
+
+	// After and Before are the regular expressions anchoring the inserted
+	// text, which is inserted after (or before) the line of the first
+	// match. The text is appended to the end of the file if both are
+	// unset.
+	After  string `mapstructure:"after" yaml:"after,omitempty" json:"after,omitempty"`
+	Before string `mapstructure:"before" yaml:"before,omitempty" json:"before,omitempty"`
+
+	// When is the CEL expression over the answers of the form (`result`),
+	// the file is not patched if it evaluates to false.
+	When string `mapstructure:"when" yaml:"when,omitempty" json:"when,omitempty"`
+
+	// Optional skips the patch if the file does not exist, else it fails.
+	Optional bool `mapstructure:"optional" yaml:"optional,omitempty" json:"optional,omitempty"`
 }
 
 type CodeTemplateStatus struct {
 	core.Status        `mapstructure:",squash" yaml:",inline"`
 	GeneratedCodeFiles []CodeTemplateStatusResult `mapstructure:"result" yaml:"result" json:"result"`
+
+	// PatchedFiles are the rendered patches of the existing files.
+	PatchedFiles []CodeTemplatePatchResult `mapstructure:"patches,omitempty" yaml:"patches,omitempty" json:"patches,omitempty"`
+}
+
+type CodeTemplatePatchResult struct {
+	File     string `mapstructure:"file" yaml:"file" json:"file"`
+	Type     string `mapstructure:"type" yaml:"type" json:"type"`
+	Patch    string `mapstructure:"patch" yaml:"patch" json:"patch"`
+	After    string `mapstructure:"after,omitempty" yaml:"after,omitempty" json:"after,omitempty"`
+	Before   string `mapstructure:"before,omitempty" yaml:"before,omitempty" json:"before,omitempty"`
+	Optional bool   `mapstructure:"optional,omitempty" yaml:"optional,omitempty" json:"optional,omitempty"`
+
+	// Action is the action taken on the file, it is empty until the file
+	// is patched.
+	Action string `mapstructure:"action,omitempty" yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// Checksum returns the checksum of the rendered patch, which identifies
+// the patch of the file regardless of its order.
+func (p CodeTemplatePatchResult) Checksum() string {
+	return Checksum([]byte(strings.Join([]string{p.Type, p.Patch, p.After, p.Before}, "\x00")))
 }
 
 type CodeTemplateStatusResult struct {
-	File string `mapstructure:"file" yaml:"file" json:"file"`
-	Code string `mapstructure:"code" yaml:"code" json:"code"`
+	File    string `mapstructure:"file" yaml:"file" json:"file"`
+	Code    string `mapstructure:"code" yaml:"code" json:"code"`
+	Policy  string `mapstructure:"policy,omitempty" yaml:"policy,omitempty" json:"policy,omitempty"`
+	OnEmpty string `mapstructure:"onEmpty,omitempty" yaml:"onEmpty,omitempty" json:"onEmpty,omitempty"`
+	Mode    string `mapstructure:"mode,omitempty" yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Encoding is the encoding of the code, it is `base64` for binary
+	// file, else it is empty.
+	Encoding string `mapstructure:"encoding,omitempty" yaml:"encoding,omitempty" json:"encoding,omitempty"`
+
+	// Action is the action taken on the file, it is empty until the file
+	// is made.
+	Action string `mapstructure:"action,omitempty" yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// IsWritten reports whether the file is written, whereas file of blank
+// code is not.
+func (r CodeTemplateStatusResult) IsWritten() bool {
+	return strings.TrimSpace(r.Code) != ""
+}
+
+// Content returns the decoded content of the generated file.
+func (r CodeTemplateStatusResult) Content() ([]byte, error) {
+	return DecodeContent(r.Code, r.Encoding)
+}
+
+// Base64Encoding is the encoding of the content of binary file.
+const Base64Encoding string = "base64"
+
+// EncodeContent encodes the content, where binary content is encoded in
+// base64 so that it is retained as is in YAML and JSON, whereas text is
+// not encoded. It returns the encoded content along with its encoding.
+func EncodeContent(b []byte) (content string, encoding string) {
+	if utf8.Valid(b) && !bytes.ContainsRune(b, 0) {
+		return string(b), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(b), Base64Encoding
+}
+
+// DecodeContent decodes the content encoded by EncodeContent.
+func DecodeContent(content string, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(content), nil
+	case Base64Encoding:
+		b, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode content: %w", err)
+		}
+
+		return b, nil
+	default:
+		return nil, fmt.Errorf("invalid encoding '%s'", encoding)
+	}
 }
 
 func (m *CodeTemplateManifest) Validate() error {
@@ -61,6 +304,12 @@ func validateCodeTemplateManifest(spec CodeTemplateSpec) error {
 
 	hashes := map[string]bool{}
 	for i, f := range spec.GenerateFiles {
+		// templated file path is checked again once it is rendered
+		err := ValidateFilePath(f.File)
+		if err != nil {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("spec.generateFiles[%v].file", i), err))
+		}
+
 		ok := hashes[f.File]
 		if ok {
 			errs = errors.Join(
@@ -71,6 +320,75 @@ func validateCodeTemplateManifest(spec CodeTemplateSpec) error {
 			)
 		}
 		hashes[f.File] = true
+
+		if f.Policy != "" && !slices.Contains(FilePolicies, f.Policy) {
+			errs = errors.Join(errs, core.NewPathError(
+				fmt.Sprintf("spec.generateFiles[%v].policy", i),
+				fmt.Errorf("policy must be either %s", english.OxfordWordSeries(FilePolicies, "or")),
+			))
+		}
+		if f.OnEmpty != "" && !slices.Contains(onEmptyChoices, f.OnEmpty) {
+			errs = errors.Join(errs, core.NewPathError(
+				fmt.Sprintf("spec.generateFiles[%v].onEmpty", i),
+				fmt.Errorf("onEmpty must be either %s", english.OxfordWordSeries(onEmptyChoices, "or")),
+			))
+		}
+		if f.Mode != "" {
+			_, err := ParseFileMode(f.Mode)
+			if err != nil {
+				errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("spec.generateFiles[%v].mode", i), err))
+			}
+		}
+		if f.As != "" {
+			asPath := fmt.Sprintf("spec.generateFiles[%v].as", i)
+			switch {
+			case f.ForEach == "":
+				errs = errors.Join(errs, core.NewPathError(asPath, errors.New("as cannot be set without forEach")))
+			case !identifierRegex.MatchString(f.As):
+				errs = errors.Join(errs, core.NewPathError(asPath, fmt.Errorf("as '%s' must be a valid identifier", f.As)))
+			case f.As == "result":
+				errs = errors.Join(errs, core.NewPathError(asPath, errors.New("as cannot be 'result', which is reserved for the answers")))
+			}
+		}
+	}
+
+	for i, f := range spec.CopyFiles {
+		prefix := fmt.Sprintf("spec.copyFiles[%v]", i)
+
+		err := ValidateFilePath(f.Source)
+		if err != nil {
+			errs = errors.Join(errs, core.NewPathError(prefix+".source", err))
+		}
+		if f.Destination != "" {
+			err := ValidateFilePath(f.Destination)
+			if err != nil {
+				errs = errors.Join(errs, core.NewPathError(prefix+".destination", err))
+			}
+		}
+
+		for j, pattern := range f.Include {
+			errs = errors.Join(errs, validateGlob(fmt.Sprintf("%s.include[%v]", prefix, j), pattern))
+		}
+		for j, pattern := range f.Exclude {
+			errs = errors.Join(errs, validateGlob(fmt.Sprintf("%s.exclude[%v]", prefix, j), pattern))
+		}
+
+		if f.Policy != "" && !slices.Contains(FilePolicies, f.Policy) {
+			errs = errors.Join(errs, core.NewPathError(
+				prefix+".policy",
+				fmt.Errorf("policy must be either %s", english.OxfordWordSeries(FilePolicies, "or")),
+			))
+		}
+		if f.Mode != "" {
+			_, err := ParseFileMode(f.Mode)
+			if err != nil {
+				errs = errors.Join(errs, core.NewPathError(prefix+".mode", err))
+			}
+		}
+	}
+
+	for i, p := range spec.PatchFiles {
+		errs = errors.Join(errs, validatePatchFile(fmt.Sprintf("spec.patchFiles[%v]", i), p))
 	}
 
 	isUniqueOpts := len(lo.Uniq(spec.Options)) == len(spec.Options)
@@ -101,13 +419,142 @@ func validateCodeTemplateManifest(spec CodeTemplateSpec) error {
 	return errs
 }
 
+// ValidateFilePath validates the path of the generated file, which must be
+// relative to the output directory without escaping it.
+func ValidateFilePath(file string) error {
+	if strings.TrimSpace(file) == "" {
+		return errors.New("file path cannot be empty")
+	}
+
+	if filepath.IsAbs(file) || strings.HasPrefix(file, "/") || strings.HasPrefix(file, `\`) {
+		return fmt.Errorf("file path '%s' must be relative to the output directory", file)
+	}
+
+	cleaned := filepath.ToSlash(filepath.Clean(file))
+	if cleaned == "." {
+		return fmt.Errorf("file path '%s' must be a file under the output directory", file)
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("file path '%s' escapes the output directory", file)
+	}
+
+	return nil
+}
+
+func validatePatchFile(prefix string, p PatchFile) error {
+	var errs error
+
+	err := ValidateFilePath(p.File)
+	if err != nil {
+		errs = errors.Join(errs, core.NewPathError(prefix+".file", err))
+	}
+
+	if !slices.Contains(patchTypes, p.Type) {
+		errs = errors.Join(errs, core.NewPathError(
+			prefix+".type",
+			fmt.Errorf("type must be either %s", english.OxfordWordSeries(patchTypes, "or")),
+		))
+	}
+
+	if strings.TrimSpace(p.Template) == "" {
+		errs = errors.Join(errs, core.NewPathError(prefix+".template", errors.New("template cannot be empty")))
+	}
+
+	anchors := map[string]string{"after": p.After, "before": p.Before}
+	for _, name := range []string{"after", "before"} {
+		anchor := anchors[name]
+		if anchor == "" {
+			continue
+		}
+
+		if p.Type != InsertPatchType {
+			errs = errors.Join(errs, core.NewPathError(
+				prefix+"."+name,
+				fmt.Errorf("%s cannot be set for type '%s'", name, p.Type),
+			))
+			continue
+		}
+
+		_, err := regexp.Compile(anchor)
+		if err != nil {
+			errs = errors.Join(errs, core.NewPathError(prefix+"."+name, fmt.Errorf("invalid regular expression: %w", err)))
+		}
+	}
+	if p.After != "" && p.Before != "" {
+		errs = errors.Join(errs, core.NewPathError(prefix, errors.New("after and before cannot be both set")))
+	}
+
+	return errs
+}
+
+func validateGlob(fieldPath string, pattern string) error {
+	if pattern == "" {
+		return core.NewPathError(fieldPath, errors.New("glob pattern cannot be empty"))
+	}
+
+	_, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), "")
+	if err != nil {
+		return core.NewPathError(fieldPath, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err))
+	}
+
+	return nil
+}
+
+// ParseFileMode parses the octal file mode of the generated file, which is
+// the permission bits only, such as `0755`.
+func ParseFileMode(mode string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("mode '%s' must be an octal number, such as '0644'", mode)
+	}
+	if m > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("mode '%s' must be permission bits within '0777'", mode)
+	}
+	if m&0600 != 0600 {
+		return 0, fmt.Errorf("mode '%s' must allow the owner to read and write", mode)
+	}
+
+	return os.FileMode(m), nil
+}
+
 func getAllowedOpts(templateKind string) ([]string, error) {
 	opts, ok := optskindMap[templateKind]
 	if !ok {
+		kinds := lo.Keys(optskindMap)
+		slices.Sort(kinds)
+
 		return nil, fmt.Errorf("illegal template kind, please select template kind under %s",
-			english.OxfordWordSeries(lo.Keys(optskindMap), "or"),
+			english.OxfordWordSeries(kinds, "or"),
 		)
 	}
 
 	return opts, nil
 }
+
+// Redacted returns the copy of the manifest, whereby the secrets are
+// redacted from the generated code (unless it is binary) and the patches,
+// so that it can be persisted.
+func (m CodeTemplateManifest) Redacted(secrets []string) CodeTemplateManifest {
+	m.Status.GeneratedCodeFiles = slices.Clone(m.Status.GeneratedCodeFiles)
+	for i, f := range m.Status.GeneratedCodeFiles {
+		if f.Encoding == "" {
+			m.Status.GeneratedCodeFiles[i].Code = redact(f.Code, secrets)
+		}
+	}
+
+	m.Status.PatchedFiles = slices.Clone(m.Status.PatchedFiles)
+	for i, f := range m.Status.PatchedFiles {
+		m.Status.PatchedFiles[i].Patch = redact(f.Patch, secrets)
+	}
+
+	return m
+}
+
+// redact replaces every secret of the text with RedactedValue.
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, RedactedValue)
+	}
+
+	return s
+}
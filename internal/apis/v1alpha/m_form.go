@@ -3,7 +3,9 @@ package v1alpha
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/dustin/go-humanize/english"
 	"github.com/nicholastcs/alchemy/internal/apis/core"
@@ -19,8 +21,19 @@ const (
 	SingleSelectTextInputType      string = "single-select-text"
 	MultiSelectTextInputType       string = "multi-select-text"
 	BooleanInputType               string = "boolean"
+	SecretInputType                string = "secret"
+	FilePathInputType              string = "file-path"
+	DateInputType                  string = "date"
+	IntegerInputType               string = "integer"
+	ListInputType                  string = "list"
+	KeyValueListInputType          string = "key-value-list"
+	ObjectInputType                string = "object"
+	ArrayInputType                 string = "array"
 )
 
+// DateLayout is the layout of the answer of date input type.
+const DateLayout string = time.DateOnly
+
 var inputTypes = map[string]bool{
 	TextInputType:                  true,
 	NumericalInputType:             true,
@@ -30,6 +43,14 @@ var inputTypes = map[string]bool{
 	SingleSelectTextInputType:      true,
 	MultiSelectTextInputType:       true,
 	BooleanInputType:               true,
+	SecretInputType:                true,
+	FilePathInputType:              true,
+	DateInputType:                  true,
+	IntegerInputType:               true,
+	ListInputType:                  true,
+	KeyValueListInputType:          true,
+	ObjectInputType:                true,
+	ArrayInputType:                 true,
 }
 
 type FormManifest struct {
@@ -41,6 +62,28 @@ type FormManifest struct {
 type FormSpec struct {
 	ConfirmationRequired bool    `yaml:"confirmationRequired" mapstructure:"confirmationRequired" json:"confirmationRequired"`
 	Fields               []Field `yaml:"fields" mapstructure:"fields" json:"fields"`
+
+	// Groups are the pages of the form in order, which group the fields by
+	// their names. If unset, every field is on a single page.
+	Groups []Group `yaml:"groups,omitempty" mapstructure:"groups" json:"groups,omitempty"`
+}
+
+// Group is the page of the form.
+type Group struct {
+	Title       string `yaml:"title" mapstructure:"title" json:"title"`
+	Description string `yaml:"description,omitempty" mapstructure:"description" json:"description,omitempty"`
+
+	// Note is the markdown shown at the top of the page.
+	Note string `yaml:"note,omitempty" mapstructure:"note" json:"note,omitempty"`
+
+	// When is the CEL expression over the answers of the shown fields
+	// before the page (`result`), the page is skipped and its fields are
+	// omitted from the form result if it evaluates to false.
+	When string `yaml:"when,omitempty" mapstructure:"when" json:"when,omitempty"`
+
+	// Fields are the names of the fields of the page, which follow the
+	// order of the fields of the form.
+	Fields []string `yaml:"fields" mapstructure:"fields" json:"fields"`
 }
 
 type FormStatus struct {
@@ -54,6 +97,60 @@ type Field struct {
 	Choices     []any       `yaml:"choices,omitempty" mapstructure:"choices" json:"choices,omitempty"`
 	InputType   string      `yaml:"inputType" mapstructure:"inputType" json:"inputType"`
 	Constraint  *Constraint `yaml:"constraint" mapstructure:"constraint" json:"constraint"`
+
+	// ShowWhen is the CEL expression over the answers of the shown fields
+	// before it (`result`), the field is hidden and omitted from the form
+	// result if it evaluates to false.
+	ShowWhen string `yaml:"showWhen,omitempty" mapstructure:"showWhen" json:"showWhen,omitempty"`
+
+	// Default is the static default of the field, which must be of the
+	// type of its input type.
+	Default any `yaml:"default,omitempty" mapstructure:"default" json:"default,omitempty"`
+
+	// DefaultExpr is the CEL expression over the answers of the shown
+	// fields before it (`result`), which takes precedence over Default.
+	// It is supported by the textual input types only, see IsTextual.
+	DefaultExpr string `yaml:"defaultExpr,omitempty" mapstructure:"defaultExpr" json:"defaultExpr,omitempty"`
+
+	// DefaultFrom is the default from the environment, which takes
+	// precedence over both DefaultExpr and Default unless it is empty.
+	DefaultFrom *DefaultSource `yaml:"defaultFrom,omitempty" mapstructure:"defaultFrom" json:"defaultFrom,omitempty"`
+
+	// Fields are the nested fields of object, or of every item of array,
+	// whose answers are nested under the answer of the field.
+	Fields []Field `yaml:"fields,omitempty" mapstructure:"fields" json:"fields,omitempty"`
+
+	// MinItems and MaxItems are the bounds of the number of items of
+	// array, where zero MaxItems is unbounded.
+	MinItems int `yaml:"minItems,omitempty" mapstructure:"minItems" json:"minItems,omitempty"`
+	MaxItems int `yaml:"maxItems,omitempty" mapstructure:"maxItems" json:"maxItems,omitempty"`
+}
+
+// DefaultSource is the source of the default from the environment, where
+// either Env or GitConfig is set.
+type DefaultSource struct {
+	// Env is the name of the environment variable, such as `USER`.
+	Env string `yaml:"env,omitempty" mapstructure:"env" json:"env,omitempty"`
+
+	// GitConfig is the key of the git config, such as `user.email`.
+	GitConfig string `yaml:"gitConfig,omitempty" mapstructure:"gitConfig" json:"gitConfig,omitempty"`
+}
+
+// IsStructured reports whether the input type has nested fields.
+func IsStructured(inputType string) bool {
+	return inputType == ObjectInputType || inputType == ArrayInputType
+}
+
+// IsTextual reports whether the input type is entered as a single value of
+// text, whose default is shown as placeholder, rather than selected, masked
+// or entered as entries.
+func IsTextual(inputType string) bool {
+	switch inputType {
+	case TextInputType, MultilineTextInputType, NumericalInputType, IntegerInputType, DateInputType:
+		return true
+	default:
+		return false
+	}
 }
 
 type Constraint struct {
@@ -82,34 +179,308 @@ func validateFormSpec(spec FormSpec) error {
 	var errs error
 
 	for i, form := range spec.Fields {
-		path := fmt.Sprintf("spec.forms[%d]", i)
+		errs = errors.Join(errs, validateField(fmt.Sprintf("spec.forms[%d]", i), form))
+	}
+
+	errs = errors.Join(errs, validateFormGroups(spec))
+
+	return errs
+}
+
+func validateField(path string, form Field) error {
+	var errs error
+
+	if len(form.Name) < 1 || len(form.Name) > 50 {
+		errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.name", path),
+			fmt.Errorf("form name '%s' must be between 1 and 50 characters", form.Name)))
+	}
+
+	if len(form.Title) < 1 || len(form.Title) > 50 {
+		errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.title", path),
+			fmt.Errorf("form title '%s' must be between 1 and 50 characters", form.Title)))
+	}
+
+	if len(form.Description) < 1 || len(form.Description) > 200 {
+		errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.description", path),
+			fmt.Errorf("form description '%s' must be between 1 and 200 characters", form.Description)))
+	}
+
+	if allowed := inputTypes[form.InputType]; !allowed {
+		errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.inputType", path),
+			fmt.Errorf("form type must be either %s", english.OxfordWordSeries(lo.Keys(inputTypes), "or"))))
+	}
+
+	if strings.Contains(form.InputType, "select") {
+		if len(form.Choices) <= 1 {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.choices", path),
+				fmt.Errorf("form with 'select' inputType must have more than 1 choice")))
+		}
+	}
+
+	errs = errors.Join(errs, validateFieldDefaults(path, form))
+	errs = errors.Join(errs, validateNestedFields(path, form))
+
+	return errs
+}
+
+// validateNestedFields validates the nested fields of object and array,
+// which are one level deep, and are neither conditional nor secret, as
+// they are repeated by the items of array.
+func validateNestedFields(path string, form Field) error {
+	var errs error
+
+	if !IsStructured(form.InputType) {
+		if len(form.Fields) > 0 {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.fields", path),
+				fmt.Errorf("fields are not supported by inputType '%s'", form.InputType)))
+		}
+		if form.MinItems != 0 || form.MaxItems != 0 {
+			errs = errors.Join(errs, core.NewPathError(path,
+				fmt.Errorf("minItems and maxItems are not supported by inputType '%s'", form.InputType)))
+		}
+
+		return errs
+	}
+
+	if form.Constraint != nil {
+		errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.constraint", path),
+			fmt.Errorf("constraint is not supported by inputType '%s', set the constraints of its fields instead", form.InputType)))
+	}
+
+	if form.DefaultFrom != nil {
+		errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.defaultFrom", path),
+			fmt.Errorf("defaultFrom is not supported by inputType '%s'", form.InputType)))
+	}
+
+	if form.InputType == ObjectInputType && (form.MinItems != 0 || form.MaxItems != 0) {
+		errs = errors.Join(errs, core.NewPathError(path,
+			fmt.Errorf("minItems and maxItems are not supported by inputType '%s'", form.InputType)))
+	}
+
+	if form.MinItems < 0 || form.MaxItems < 0 || (form.MaxItems > 0 && form.MaxItems < form.MinItems) {
+		errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.maxItems", path),
+			fmt.Errorf("maxItems %d must be either 0 (unbounded) or at least minItems %d", form.MaxItems, form.MinItems)))
+	}
+
+	if len(form.Fields) == 0 {
+		errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.fields", path),
+			fmt.Errorf("inputType '%s' must have at least 1 field", form.InputType)))
+	}
+
+	names := map[string]bool{}
+	for j, field := range form.Fields {
+		fieldPath := fmt.Sprintf("%s.fields[%d]", path, j)
+
+		if names[field.Name] {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.name", fieldPath),
+				fmt.Errorf("field '%s' is defined more than once", field.Name)))
+		}
+		names[field.Name] = true
+
+		if IsStructured(field.InputType) || field.InputType == SecretInputType {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.inputType", fieldPath),
+				fmt.Errorf("inputType '%s' is not supported by nested field", field.InputType)))
 
-		if len(form.Name) < 1 || len(form.Name) > 50 {
-			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.name", path),
-				fmt.Errorf("form name '%s' must be between 1 and 50 characters", form.Name)))
+			continue
 		}
 
-		if len(form.Title) < 1 || len(form.Title) > 50 {
+		if field.ShowWhen != "" || field.DefaultExpr != "" || field.DefaultFrom != nil {
+			errs = errors.Join(errs, core.NewPathError(fieldPath,
+				errors.New("showWhen, defaultExpr and defaultFrom are not supported by nested field")))
+		}
+
+		errs = errors.Join(errs, validateField(fieldPath, field))
+	}
+
+	return errs
+}
+
+func validateFieldDefaults(path string, field Field) error {
+	var errs error
+
+	if field.Default != nil {
+		err := CheckValueType(field, field.Default)
+		if err != nil {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.default", path), err))
+		}
+	}
+
+	if field.DefaultExpr != "" && !IsTextual(field.InputType) {
+		errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.defaultExpr", path),
+			fmt.Errorf("defaultExpr is not supported by inputType '%s'", field.InputType)))
+	}
+
+	if field.DefaultFrom != nil {
+		if (field.DefaultFrom.Env == "") == (field.DefaultFrom.GitConfig == "") {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.defaultFrom", path),
+				errors.New("either env or gitConfig must be set")))
+		}
+	}
+
+	return errs
+}
+
+// CheckValueType checks whether the value is of the type of the input type
+// of the field, where the value of selection must be one of the choices.
+func CheckValueType(field Field, value any) error {
+	switch field.InputType {
+	case TextInputType, MultilineTextInputType, SecretInputType, FilePathInputType:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("value `%v` must be string", value)
+		}
+	case NumericalInputType:
+		if _, ok := toNumber(value); !ok {
+			return fmt.Errorf("value `%v` must be number", value)
+		}
+	case IntegerInputType:
+		if v, ok := toNumber(value); !ok || v != math.Trunc(v) {
+			return fmt.Errorf("value `%v` must be integer", value)
+		}
+	case DateInputType:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("value `%v` must be date in the format of YYYY-MM-DD", value)
+		}
+		_, err := ParseDate(s)
+		if err != nil {
+			return err
+		}
+	case ListInputType:
+		list, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("value `%v` must be list", value)
+		}
+		for _, item := range list {
+			if _, ok := item.(string); !ok {
+				return fmt.Errorf("item `%v` must be string", item)
+			}
+		}
+	case KeyValueListInputType:
+		_, err := ToKeyValueList(value)
+		if err != nil {
+			return err
+		}
+	case ObjectInputType, ArrayInputType:
+		return fmt.Errorf("default is not supported by inputType '%s', set the defaults of its fields instead", field.InputType)
+	case BooleanInputType:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("value `%v` must be boolean", value)
+		}
+	case SingleSelectTextInputType, SingleSelectNumericalInputType:
+		return CheckChoice(field, value)
+	case MultiSelectTextInputType, MultiSelectNumericalInputType:
+		list, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("value `%v` must be list", value)
+		}
+		for _, item := range list {
+			err := CheckChoice(field, item)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckChoice checks whether the value is one of the choices of the field.
+func CheckChoice(field Field, value any) error {
+	numerical := strings.Contains(field.InputType, "numerical")
+
+	for _, choice := range field.Choices {
+		if numerical {
+			v, ok := toNumber(value)
+			c, _ := toNumber(choice)
+			if ok && v == c {
+				return nil
+			}
+
+			continue
+		}
+
+		if s, ok := value.(string); ok && s == fmt.Sprintf("%v", choice) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("value `%v` is not one of the choices %v", value, field.Choices)
+}
+
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func validateFormGroups(spec FormSpec) error {
+	if len(spec.Groups) == 0 {
+		return nil
+	}
+
+	var errs error
+
+	indexes := map[string]int{}
+	for i, field := range spec.Fields {
+		indexes[field.Name] = i
+	}
+
+	grouped := map[string]bool{}
+	last := -1
+	for i, group := range spec.Groups {
+		path := fmt.Sprintf("spec.groups[%d]", i)
+
+		if len(group.Title) < 1 || len(group.Title) > 50 {
 			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.title", path),
-				fmt.Errorf("form title '%s' must be between 1 and 50 characters", form.Title)))
+				fmt.Errorf("group title '%s' must be between 1 and 50 characters", group.Title)))
 		}
 
-		if len(form.Description) < 1 || len(form.Description) > 200 {
+		if len(group.Description) > 200 {
 			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.description", path),
-				fmt.Errorf("form description '%s' must be between 1 and 200 characters", form.Description)))
+				fmt.Errorf("group description '%s' must be at most 200 characters", group.Description)))
 		}
 
-		if allowed := inputTypes[form.InputType]; !allowed {
-			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.inputType", path),
-				fmt.Errorf("form type must be either %s", english.OxfordWordSeries(lo.Keys(inputTypes), "or"))))
+		if len(group.Fields) == 0 {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.fields", path),
+				errors.New("group must have at least 1 field")))
 		}
 
-		if strings.Contains(form.InputType, "select") {
-			if len(form.Choices) <= 1 {
-				errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.choices", path),
-					fmt.Errorf("form with 'select' inputType must have more than 1 choice")))
+		for j, name := range group.Fields {
+			fieldPath := fmt.Sprintf("%s.fields[%d]", path, j)
+
+			index, ok := indexes[name]
+			switch {
+			case !ok:
+				errs = errors.Join(errs, core.NewPathError(fieldPath, fmt.Errorf("field '%s' is not defined", name)))
+			case grouped[name]:
+				errs = errors.Join(errs, core.NewPathError(fieldPath, fmt.Errorf("field '%s' is grouped more than once", name)))
+			case index < last:
+				errs = errors.Join(errs, core.NewPathError(fieldPath,
+					fmt.Errorf("field '%s' must follow the order of the fields of the form", name)))
+			}
+
+			grouped[name] = true
+			if ok {
+				last = max(last, index)
 			}
 		}
 	}
+
+	for i, field := range spec.Fields {
+		if !grouped[field.Name] {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("spec.fields[%d]", i),
+				fmt.Errorf("field '%s' must be grouped as the form has groups", field.Name)))
+		}
+	}
+
 	return errs
 }
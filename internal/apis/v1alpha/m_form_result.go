@@ -3,9 +3,12 @@ package v1alpha
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nicholastcs/alchemy/internal/apis/core"
 )
@@ -26,6 +29,13 @@ type FormResultSpec struct {
 type FormResultStatus struct {
 	core.Status  `yaml:",inline" mapstructure:",squash"`
 	MappedResult map[string]any `yaml:"mappedResult,omitempty" mapstructure:"mappedResult,omitempty" json:"mappedResult,omitempty"`
+
+	// CodeTemplateReference is the code template consumed the result, it
+	// is nil if the result is not consumed yet.
+	CodeTemplateReference *core.Base `yaml:"codeTemplateReference,omitempty" mapstructure:"codeTemplateReference,omitempty" json:"codeTemplateReference,omitempty"`
+
+	// GeneratedFiles are the paths of files generated from the result.
+	GeneratedFiles []string `yaml:"generatedFiles,omitempty" mapstructure:"generatedFiles,omitempty" json:"generatedFiles,omitempty"`
 }
 
 func NewFormResult(name string, namespace string, formReference core.Base, result map[string]any) (*FormResultManifest, error) {
@@ -84,35 +94,333 @@ func (m *FormResultSpec) NewEmptyResult(key string, value any, fieldType string)
 	m.Result[key] = value
 }
 
+// ArrayResult is the answers of array in progress, where the items are
+// answered up to the maximum items, but only the first Count items are
+// taken.
+type ArrayResult struct {
+	Count int
+	Items []map[string]any
+}
+
 // ConvertResultToNative converts certain string types which are actually
-// should be numerical into 64-bit float.
+// should be numerical into 64-bit float, integer into 64-bit integer, the
+// entries of list and key-value-list into their lists, and the answers of
+// object and array into nested maps and list of maps.
 func (m *FormResultSpec) ConvertResultToNative() error {
 	m.indirectResults()
 
 	// convert to native for certain types that are handled as strings
 	// literal in charmbracelet/huh API
 	for name, typeHint := range m.TypeHintByResult {
-		if strings.Contains(typeHint, "numerical") {
-			val := m.Result[name]
+		// the nested fields, which are hinted as `parent.field`, are
+		// converted along with their parents.
+		if strings.Contains(name, ".") {
+			continue
+		}
+
+		val, err := m.toNative(name, typeHint, m.Result[name])
+		if err != nil {
+			return err
+		}
+
+		m.Result[name] = val
+	}
+
+	return nil
+}
+
+// toNative converts the answer of the type hint into native, where the
+// name is the name of the answer, or the `parent.field` of the nested
+// answer.
+func (m *FormResultSpec) toNative(name string, typeHint string, val any) (any, error) {
+	if v := reflect.Indirect(reflect.ValueOf(val)); v.IsValid() {
+		val = v.Interface()
+	}
+
+	switch {
+	case strings.Contains(typeHint, "numerical"):
+		// values are already native, such as results of
+		// multi-select or the ones supplied from values file.
+		switch val.(type) {
+		case float64, []float64:
+			return val, nil
+		}
+
+		valueLiteral := fmt.Sprintf("%v", val)
+
+		if strings.TrimSpace(valueLiteral) == "" {
+			return 0, nil
+		}
+
+		return strconv.ParseFloat(valueLiteral, 64)
+
+	case typeHint == IntegerInputType:
+		if _, ok := val.(int64); ok {
+			return val, nil
+		}
+
+		valueLiteral := strings.TrimSpace(fmt.Sprintf("%v", val))
+
+		if valueLiteral == "" {
+			return int64(0), nil
+		}
+
+		return strconv.ParseInt(valueLiteral, 10, 64)
+
+	case typeHint == DateInputType:
+		s, ok := val.(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			return val, nil
+		}
+
+		_, err := ParseDate(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.TrimSpace(s), nil
+
+	case typeHint == ListInputType:
+		if s, ok := val.(string); ok {
+			return ParseList(s), nil
+		}
+
+		return val, nil
+
+	case typeHint == KeyValueListInputType:
+		if s, ok := val.(string); ok {
+			return ParseKeyValueList(s)
+		}
+
+		return val, nil
+
+	case typeHint == ObjectInputType:
+		item, ok := val.(map[string]any)
+		if !ok {
+			return val, nil
+		}
+
+		return m.itemToNative(name, item)
+
+	case typeHint == ArrayInputType:
+		var items []map[string]any
+		switch v := val.(type) {
+		case ArrayResult:
+			items = v.Items[:min(max(v.Count, 0), len(v.Items))]
+		case []map[string]any:
+			items = v
+		default:
+			return val, nil
+		}
+
+		output := []map[string]any{}
+		for _, item := range items {
+			converted, err := m.itemToNative(name, item)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, converted)
+		}
+
+		return output, nil
+	}
+
+	return val, nil
+}
+
+// itemToNative converts the answers of the object, or the item of the
+// array, by the type hints of the nested fields of the parent.
+func (m *FormResultSpec) itemToNative(parent string, item map[string]any) (map[string]any, error) {
+	output := map[string]any{}
+	for key, val := range item {
+		converted, err := m.toNative(parent+"."+key, m.TypeHintByResult[parent+"."+key], val)
+		if err != nil {
+			return nil, err
+		}
+		output[key] = converted
+	}
+
+	return output, nil
+}
+
+// ParseDate parses the date in the format of YYYY-MM-DD.
+func ParseDate(s string) (time.Time, error) {
+	t, err := time.Parse(DateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("value `%s` must be date in the format of YYYY-MM-DD", s)
+	}
+
+	return t, nil
+}
+
+// ParseList parses the entries of list, one entry per line, where the
+// blank lines are skipped.
+func ParseList(s string) []string {
+	output := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		output = append(output, strings.TrimSpace(line))
+	}
+
+	return output
+}
+
+// ParseKeyValueList parses the entries of key-value-list, one `key=value`
+// entry per line, into the list of entries of `key` and `value`, where the
+// blank lines are skipped.
+func ParseKeyValueList(s string) ([]map[string]string, error) {
+	output := []map[string]string{}
+	for i, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("line %d: entry `%s` must be in the form of key=value", i+1, strings.TrimSpace(line))
+		}
+
+		output = append(output, map[string]string{
+			"key":   strings.TrimSpace(key),
+			"value": strings.TrimSpace(value),
+		})
+	}
+
+	return output, nil
+}
+
+// ToKeyValueList converts the value of key-value-list, which is either the
+// entries of `key=value` lines, the list of entries of `key` and `value`,
+// or the map (in the order of its keys), into the list of entries.
+func ToKeyValueList(value any) ([]map[string]string, error) {
+	switch v := value.(type) {
+	case []map[string]string:
+		return v, nil
 
-			valueLiteral := fmt.Sprintf("%v", val)
+	case string:
+		return ParseKeyValueList(v)
 
-			if strings.TrimSpace(valueLiteral) == "" {
-				m.Result[name] = 0
+	case map[string]any:
+		output := []map[string]string{}
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			s, err := scalarString(v[key])
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, map[string]string{"key": key, "value": s})
+		}
+
+		return output, nil
+
+	case []any:
+		output := []map[string]string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				entries, err := ParseKeyValueList(s)
+				if err != nil {
+					return nil, err
+				}
+				output = append(output, entries...)
 
 				continue
 			}
 
-			c, err := strconv.ParseFloat(valueLiteral, 64)
+			entry, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("entry `%v` must have key and value", item)
+			}
+			key, ok := entry["key"].(string)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("entry `%v` must have key and value", item)
+			}
+			s, err := scalarString(entry["value"])
 			if err != nil {
-				return err
+				return nil, err
 			}
+			output = append(output, map[string]string{"key": key, "value": s})
+		}
+
+		return output, nil
+
+	default:
+		return nil, fmt.Errorf("value `%v` must be list of entries of key and value", value)
+	}
+}
+
+func scalarString(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprintf("%v", v), nil
+	default:
+		return "", fmt.Errorf("value `%v` must be scalar", value)
+	}
+}
+
+// RedactedValue is the answer of secret field wherever the result is
+// persisted, dumped or logged.
+const RedactedValue string = "<redacted>"
+
+// RedactResult returns the copy of the answers, whereby the answers of the
+// secret fields, by their type hints, are redacted.
+func RedactResult(result map[string]any, typeHints map[string]string) map[string]any {
+	if result == nil {
+		return nil
+	}
 
-			m.Result[name] = c
+	output := maps.Clone(result)
+	for name, typeHint := range typeHints {
+		if _, ok := output[name]; ok && typeHint == SecretInputType {
+			output[name] = RedactedValue
 		}
 	}
 
-	return nil
+	return output
+}
+
+// Redacted returns the copy of the spec, whereby the answers of the secret
+// fields are redacted.
+func (m FormResultSpec) Redacted() FormResultSpec {
+	m.Result = RedactResult(m.Result, m.TypeHintByResult)
+	m.TypeHintByResult = maps.Clone(m.TypeHintByResult)
+
+	return m
+}
+
+// Redacted returns the copy of the manifest, whereby the answers of the
+// secret fields are redacted, so that it can be persisted.
+func (m FormResultManifest) Redacted() FormResultManifest {
+	m.Spec = m.Spec.Redacted()
+
+	return m
+}
+
+// Secrets returns the non-empty answers of the secret fields.
+func (m FormResultSpec) Secrets() []string {
+	output := []string{}
+	for name, typeHint := range m.TypeHintByResult {
+		if typeHint != SecretInputType {
+			continue
+		}
+
+		val := reflect.Indirect(reflect.ValueOf(m.Result[name]))
+		if !val.IsValid() {
+			continue
+		}
+
+		s, ok := val.Interface().(string)
+		if !ok || s == "" || s == RedactedValue {
+			continue
+		}
+		output = append(output, s)
+	}
+
+	return output
 }
 
 // indirectResults simply indirects all values under m.Results into value
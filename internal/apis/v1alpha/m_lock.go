@@ -0,0 +1,240 @@
+package v1alpha
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+)
+
+// LockFileName is the name of the lock file written into the directory of
+// the generated code.
+const LockFileName string = ".alchemy.lock.yaml"
+
+// LockManifest records what was generated into a directory, which is the
+// references of form and code template, the answers and the generated
+// files, so that drift can be detected and the code can be upgraded.
+type LockManifest struct {
+	core.Base `yaml:",inline" mapstructure:",squash"`
+	Spec      LockSpec `yaml:"spec" mapstructure:"spec" json:"spec"`
+}
+
+type LockSpec struct {
+	// AlchemyVersion is the version of Alchemy generated the code.
+	AlchemyVersion string `yaml:"alchemyVersion" mapstructure:"alchemyVersion" json:"alchemyVersion"`
+
+	FormReference         LockReference `yaml:"formReference" mapstructure:"formReference" json:"formReference"`
+	CodeTemplateReference LockReference `yaml:"codeTemplateReference" mapstructure:"codeTemplateReference" json:"codeTemplateReference"`
+
+	// Result is the answers of the form, where the answers of the secret
+	// fields are redacted.
+	Result map[string]any `yaml:"result" mapstructure:"result" json:"result"`
+
+	Files []LockFile `yaml:"files" mapstructure:"files" json:"files"`
+
+	// Patches are the patches applied on the existing files, which are
+	// never applied again on the directory, as patches such as adding to
+	// the end of list by JSON Patch are not idempotent.
+	Patches []LockPatch `yaml:"patches,omitempty" mapstructure:"patches" json:"patches,omitempty"`
+}
+
+// LockReference is the reference of the manifest, whereby the hash is the
+// content hash of its spec.
+type LockReference struct {
+	Name      string `yaml:"name" mapstructure:"name" json:"name"`
+	Namespace string `yaml:"namespace" mapstructure:"namespace" json:"namespace"`
+	Hash      string `yaml:"hash" mapstructure:"hash" json:"hash"`
+}
+
+type LockFile struct {
+	// Path is the path of the file, relative to the directory of the lock
+	// file.
+	Path     string `yaml:"path" mapstructure:"path" json:"path"`
+	Checksum string `yaml:"checksum" mapstructure:"checksum" json:"checksum"`
+
+	// Content is the generated content, which is the base of three-way
+	// merge during upgrade, where the secrets are redacted. It is empty
+	// for binary file, as binary file is never merged, thus only its
+	// checksum is recorded.
+	Content string `yaml:"content,omitempty" mapstructure:"content" json:"content,omitempty"`
+
+	// Encoding is the encoding of the generated content, it is `base64`
+	// for binary file, else it is empty.
+	Encoding string `yaml:"encoding,omitempty" mapstructure:"encoding" json:"encoding,omitempty"`
+}
+
+type LockPatch struct {
+	// File is the path of the patched file, relative to the directory of
+	// the lock file.
+	File     string `yaml:"file" mapstructure:"file" json:"file"`
+	Checksum string `yaml:"checksum" mapstructure:"checksum" json:"checksum"`
+}
+
+// Patched returns whether the patch is applied already on the file.
+func (s LockSpec) Patched(p CodeTemplatePatchResult) bool {
+	checksum := p.Checksum()
+
+	return slices.ContainsFunc(s.Patches, func(l LockPatch) bool {
+		return l.File == p.File && l.Checksum == checksum
+	})
+}
+
+// File returns the locked file of the path, it returns nil if the path is
+// not locked.
+func (s LockSpec) File(path string) *LockFile {
+	for i, f := range s.Files {
+		if f.Path == path {
+			return &s.Files[i]
+		}
+	}
+
+	return nil
+}
+
+// NewLock returns the lock of the code generated from the form result and
+// the code template, where only the files that are written are recorded,
+// whereas the files skipped by their policy are not. The answers of the
+// secret fields are redacted, from the answers and the generated content
+// alike.
+func NewLock(
+	alchemyVersion string,
+	form FormManifest,
+	result FormResultManifest,
+	codeTemplate CodeTemplateManifest,
+) (*LockManifest, error) {
+	formHash, err := ContentHash(form.Spec)
+	if err != nil {
+		return nil, err
+	}
+	codeTemplateHash, err := ContentHash(codeTemplate.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := result.Spec.Secrets()
+
+	files := []LockFile{}
+	for _, f := range codeTemplate.Status.GeneratedCodeFiles {
+		if !f.IsWritten() || f.Action == FileSkipped {
+			continue
+		}
+
+		content, err := f.Content()
+		if err != nil {
+			return nil, err
+		}
+
+		// the checksum is of the generated content as is, so that
+		// the local edit is detected regardless of the redaction.
+		file := LockFile{
+			Path:     f.File,
+			Checksum: Checksum(content),
+			Encoding: f.Encoding,
+		}
+		if f.Encoding == "" {
+			file.Content = redact(f.Code, secrets)
+		}
+
+		files = append(files, file)
+	}
+
+	patches := []LockPatch{}
+	for _, p := range codeTemplate.Status.PatchedFiles {
+		if !slices.Contains([]string{FilePatched, FileUnchanged, FileApplied}, p.Action) {
+			continue
+		}
+
+		patches = append(patches, LockPatch{File: p.File, Checksum: p.Checksum()})
+	}
+
+	output := &LockManifest{
+		Base: core.Base{
+			APIVersion: "alchemy.io/v1alpha/internal",
+			Kind:       "Lock",
+			Metadata: core.Metadata{
+				Name:      "lock",
+				Namespace: form.Metadata.Namespace,
+			},
+		},
+		Spec: LockSpec{
+			AlchemyVersion: alchemyVersion,
+			FormReference: LockReference{
+				Name:      form.Metadata.Name,
+				Namespace: form.Metadata.Namespace,
+				Hash:      formHash,
+			},
+			CodeTemplateReference: LockReference{
+				Name:      codeTemplate.Metadata.Name,
+				Namespace: codeTemplate.Metadata.Namespace,
+				Hash:      codeTemplateHash,
+			},
+			Result:  result.Spec.Redacted().Result,
+			Files:   files,
+			Patches: patches,
+		},
+	}
+
+	err = output.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+func (m *LockManifest) Validate() error {
+	var errs error
+	errs = errors.Join(errs, m.Base.Validate())
+	errs = errors.Join(errs, validateLockSpec(m.Spec))
+
+	return errs
+}
+
+func validateLockSpec(spec LockSpec) error {
+	var errs error
+
+	if spec.FormReference.Name == "" {
+		errs = errors.Join(errs, core.NewPathError("spec.formReference.name", errors.New("form name cannot be empty")))
+	}
+	if spec.CodeTemplateReference.Name == "" {
+		errs = errors.Join(errs, core.NewPathError("spec.codeTemplateReference.name", errors.New("code template name cannot be empty")))
+	}
+
+	for i, f := range spec.Files {
+		err := ValidateFilePath(f.Path)
+		if err != nil {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("spec.files[%d].path", i), err))
+		}
+	}
+
+	for i, p := range spec.Patches {
+		err := ValidateFilePath(p.File)
+		if err != nil {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("spec.patches[%d].file", i), err))
+		}
+	}
+
+	return errs
+}
+
+// ContentHash returns the sha256 hash of the JSON form of the value.
+func ContentHash(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("unable to hash content: %w", err)
+	}
+
+	return Checksum(b), nil
+}
+
+// Checksum returns the sha256 checksum of the content in the form of
+// `sha256:<hex>`.
+func Checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,107 @@
+package environment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/core/experimentation"
+	"github.com/samber/lo"
+	"github.com/spf13/afero"
+)
+
+// DefaultConfigPath returns the default path of the config file, which is
+// `$HOME/.alchemy.yaml`.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine home directory: %w", err)
+	}
+
+	return filepath.Join(dir, ".alchemy.yaml"), nil
+}
+
+// NewConfig returns an empty config manifest.
+func NewConfig() *core.ConfigManifest {
+	return &core.ConfigManifest{
+		Base: core.Base{
+			APIVersion: "alchemy.io/core",
+			Kind:       "Config",
+			Metadata: core.Metadata{
+				Name:      "alchemy",
+				Namespace: "default",
+			},
+		},
+		Spec: core.ConfigSpec{
+			EnableLogs: lo.ToPtr(true),
+			Configs:    []core.SubConfig{},
+		},
+	}
+}
+
+// LoadConfig reads the config file, it returns nil config without error
+// if the file does not exist.
+func LoadConfig(path string) (*core.ConfigManifest, error) {
+	fs := afero.NewOsFs()
+
+	in, err := afero.ReadFile(fs, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file '%s': %w", path, err)
+	}
+
+	var m core.AbstractedManifest
+	err = yaml.Unmarshal(in, &m)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config file '%s': %w", path, err)
+	}
+
+	if m.APIVersion != "alchemy.io/core" || m.Kind != "Config" {
+		return nil, fmt.Errorf("config file '%s' must be of apiVersion 'alchemy.io/core' and kind 'Config'", path)
+	}
+
+	config, err := experimentation.ToActualManifest[*core.ConfigManifest](m)
+	if err != nil {
+		return nil, err
+	}
+	config.SetFilePath(path)
+
+	err = config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file '%s': %w", path, err)
+	}
+
+	return config, nil
+}
+
+// SaveConfig writes the config into the config file.
+func SaveConfig(path string, config *core.ConfigManifest) error {
+	err := config.Validate()
+	if err != nil {
+		return err
+	}
+
+	// status and annotations are runtime info, they are not persisted
+	persisted := *config
+	persisted.Status = core.Status{}
+	persisted.Metadata.Annotations = nil
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b,
+		yaml.IndentSequence(true),
+		yaml.Indent(2),
+	)
+
+	err = encoder.Encode(persisted)
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(afero.NewOsFs(), path, b.Bytes(), 0644)
+}
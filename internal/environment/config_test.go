@@ -0,0 +1,94 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveThenLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".alchemy.yaml")
+
+	// non-existing config file is not an error
+	c, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c = NewConfig()
+	c.Spec.Configs = append(c.Spec.Configs, core.SubConfig{
+		Name:      "platform",
+		Type:      core.ConfigDirectoryType,
+		Directory: "./manifests",
+		Sources:   []string{"git+file:///srv/git/golden-patterns.git"},
+		Namespace: "k8s.io",
+		Retention: 20,
+	})
+
+	// selecting unknown config is an error
+	err = c.SelectConfig("unknown")
+	assert.Error(t, err)
+
+	err = c.SelectConfig("platform")
+	require.NoError(t, err)
+
+	err = SaveConfig(path, c)
+	require.NoError(t, err)
+
+	c, err = LoadConfig(path)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	require.NotNil(t, c.Selected())
+
+	assert.Equal(t, "k8s.io", c.Selected().Namespace)
+	assert.Equal(t, 20, c.Selected().Retention)
+	assert.Equal(t, []string{"dir://./manifests", "git+file:///srv/git/golden-patterns.git"}, c.Selected().ManifestSources())
+}
+
+func TestConfigValidation(t *testing.T) {
+	c := NewConfig()
+	c.Spec.SelectedConfig = "unknown"
+	c.Spec.Configs = append(c.Spec.Configs,
+		core.SubConfig{Name: "platform", Type: core.ConfigDirectoryType},
+		core.SubConfig{Name: "platform", LogLevel: "verbose", Retention: -1},
+	)
+
+	err := c.Validate()
+	require.Error(t, err)
+
+	assert.ErrorContains(t, err, "at spec.configs[0].directory")
+	assert.ErrorContains(t, err, "at spec.configs[1].name")
+	assert.ErrorContains(t, err, "at spec.configs[1].logLevel")
+	assert.ErrorContains(t, err, "at spec.configs[1].retention")
+	assert.ErrorContains(t, err, "at spec.selectedConfig")
+}
+
+func TestLoadConfigEnableLogsDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".alchemy.yaml")
+
+	// hand-written config without enableLogs
+	err := os.WriteFile(path, []byte(`apiVersion: alchemy.io/core
+kind: Config
+metadata:
+  name: alchemy
+spec:
+  configs: []
+`), 0644)
+	require.NoError(t, err)
+
+	c, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.True(t, c.Spec.LogsEnabled())
+
+	c.Spec.EnableLogs = lo.ToPtr(false)
+	require.NoError(t, SaveConfig(path, c))
+
+	c, err = LoadConfig(path)
+	require.NoError(t, err)
+	assert.False(t, c.Spec.LogsEnabled())
+}
@@ -13,6 +13,40 @@ import (
 
 var embed fs.FS
 
+// embedSourceURI is the source URI of the embedded manifests, which is
+// always loaded first.
+const embedSourceURI string = "embed://"
+
+func init() {
+	system.RegisterSourceProvider(embedSourceURI,
+		func(_ string, allowedAPIs []string, log *logrus.Entry) (system.ManifestSourceProvider, string, error) {
+			provider, err := system.NewFileLoader(embed, allowedAPIs, log)
+
+			// TODO: we take in "embed" as a standardized directory for now
+			return provider, "embed", err
+		},
+	)
+}
+
+// Env is the environment database, along with the manifest sources its
+// manifests are loaded from, which serve the files of code templates.
+type Env struct {
+	system.Db
+
+	sources map[string]*system.ManifestSource
+}
+
+// PersistedAPIs are the APIs persisted into the store of the environment.
+//
+// CodeTemplate is left out, as it is loaded from the manifest sources on
+// every run, where the persisted one would shadow the template edited or
+// removed from its source. The outcome of the generation is kept on the
+// FormResult instead (by its code template reference and generated files),
+// along with the checksum of every file in the lock file.
+var PersistedAPIs = []string{
+	"alchemy.io/v1alpha/internal/FormResult",
+}
+
 func PreloadEmbedFS(fs fs.FS) error {
 	if fs == nil {
 		return errors.New("embed fs cannot be empty")
@@ -23,10 +57,27 @@ func PreloadEmbedFS(fs fs.FS) error {
 	return nil
 }
 
-func New(log *logrus.Entry) (*system.Db, error) {
+// New loads manifests into a new environment database. Manifests are
+// loaded from the embedded fs first, followed by the manifest sources in
+// order. Should the same resource (by apiVersion, kind, namespace and
+// name) be found on multiple sources, it is a conflict unless
+// allowOverrides is set, where the latter source takes precedence.
+//
+// Every loaded source is reported as readonly Source resource.
+//
+// Form results are persisted into the store, so that results of the
+// previous runs are available across invocations. Nothing is persisted if
+// the store is nil.
+func New(log *logrus.Entry, sources []string, store system.Store, allowOverrides bool) (*Env, error) {
 	c := log.WithField("context", "init")
 
-	db, err := system.NewLocalDB(log)
+	var db *system.Db
+	var err error
+	if store != nil {
+		db, err = system.NewPersistentDB(log, store, PersistedAPIs)
+	} else {
+		db, err = system.NewLocalDB(log)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -34,15 +85,12 @@ func New(log *logrus.Entry) (*system.Db, error) {
 	// retrieve allowed APIs
 	allowedApis := experimentation.AllowedAPIs()
 
-	loader, err := system.NewFileLoader(embed, allowedApis, log)
-	if err != nil {
-		return nil, err
-	}
-
-	manifests, err := loader.GetFiles("embed")
+	manifests, sourceManifests, loaded, err := loadSources(
+		append([]string{embedSourceURI}, sources...), allowedApis, allowOverrides, log)
 	if err != nil {
 		return nil, err
 	}
+	manifests = append(manifests, sourceManifests...)
 
 	// load API metadata too...
 	metas, err := experimentation.GetAPIsMetadata()
@@ -56,15 +104,17 @@ func New(log *logrus.Entry) (*system.Db, error) {
 
 		mErr, conversionErr := experimentation.DeepValidate(m)
 		if conversionErr != nil {
-			return nil, fmt.Errorf("%s/%s %s of namespace '%s' :%w",
-				m.APIVersion, m.Kind, m.Metadata.Name, m.Metadata.Namespace, conversionErr)
+			return nil, fmt.Errorf("%s/%s %s of namespace '%s' (document %s of '%s'): %w",
+				m.APIVersion, m.Kind, m.Metadata.Name, m.Metadata.Namespace,
+				m.GetDocumentIndex(), m.GetFilePath(), conversionErr)
 		}
 		if mErr != nil {
-			manifests[i].Status.SetError(mErr)
+			manifests[i].Status.SetError(fmt.Errorf("document %s of '%s': %w",
+				m.GetDocumentIndex(), m.GetFilePath(), mErr))
 
 			mLog.WithError(mErr).Infof("resource %s under namespace %s has error", m.Metadata.Name, m.Metadata.Namespace)
 		}
-		manifests[i].Status.SetCondition(core.ResourceReady, mErr == nil)
+		manifests[i].Status.SetCondition(core.ResourceReady, !manifests[i].Status.HasErr())
 
 		mLog.Infof("resource %s under namespace %s is ready", m.Metadata.Name, m.Metadata.Namespace)
 	}
@@ -74,5 +124,5 @@ func New(log *logrus.Entry) (*system.Db, error) {
 		return nil, err
 	}
 
-	return db, nil
+	return &Env{Db: *db, sources: loaded}, nil
 }
@@ -1,8 +1,13 @@
 package environment
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/nicholastcs/alchemy/internal/apis/core"
 	"github.com/nicholastcs/alchemy/internal/utils"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
@@ -82,8 +87,124 @@ func TestNewEnv(t *testing.T) {
 
 	require.NoError(t, err)
 
-	c, err := New(logT)
+	c, err := New(logT, nil, nil, false)
 
 	require.NoError(t, err)
 	assert.NotNil(t, c)
 }
+
+func TestNewEnvWithDirectorySource(t *testing.T) {
+	uFs := afero.NewMemMapFs()
+
+	err := uFs.Mkdir("embed/", 0755)
+	require.NoError(t, err)
+
+	err = afero.WriteFile(uFs, "embed/code-template.yaml", []byte(file1), 0644)
+	require.NoError(t, err)
+
+	err = PreloadEmbedFS(afero.NewIOFS(uFs))
+	require.NoError(t, err)
+
+	// the same resource on the directory source overrides the embedded one
+	// once overrides are allowed
+	dir := t.TempDir()
+	override := strings.ReplaceAll(file1, "5.72.1", "5.80.0")
+	err = os.WriteFile(filepath.Join(dir, "code-template.yaml"), []byte(override), 0644)
+	require.NoError(t, err)
+
+	c, err := New(logT, []string{"dir://" + dir}, nil, true)
+	require.NoError(t, err)
+
+	m, err := c.Get("alchemy.io/v1alpha", "CodeTemplate", "test-template", "terraform")
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	assert.Equal(t, filepath.Join(dir, "code-template.yaml"), m.GetFilePath())
+	assert.Contains(t, fmt.Sprintf("%v", m.Spec), "5.80.0")
+
+	_, err = c.Assets(m.Base)
+	require.NoError(t, err)
+
+	// the sources loaded are of their own environment only
+	other, err := New(logT, nil, nil, false)
+	require.NoError(t, err)
+
+	_, err = other.Assets(m.Base)
+	assert.ErrorContains(t, err, "is not loaded")
+
+	_, err = New(logT, []string{"unknown://" + dir}, nil, false)
+	assert.Error(t, err)
+}
+
+func TestNewEnvSourceConflicts(t *testing.T) {
+	uFs := afero.NewMemMapFs()
+
+	err := uFs.Mkdir("embed/", 0755)
+	require.NoError(t, err)
+
+	err = afero.WriteFile(uFs, "embed/code-template.yaml", []byte(file1), 0644)
+	require.NoError(t, err)
+
+	err = PreloadEmbedFS(afero.NewIOFS(uFs))
+	require.NoError(t, err)
+
+	// the same resource twice within a single source is a conflict
+	dir := t.TempDir()
+	for _, name := range []string{"a.yaml", "b.yaml"} {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(file1), 0644)
+		require.NoError(t, err)
+	}
+
+	c, err := New(logT, []string{"dir://" + dir}, nil, true)
+	require.NoError(t, err)
+
+	m, err := c.Get("alchemy.io/v1alpha", "CodeTemplate", "test-template", "terraform")
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.True(t, m.Status.HasErr())
+	assert.Equal(t, "dir://"+dir, m.GetSource())
+
+	sources, err := c.GetByGVK("alchemy.io/core/readonly", "Source")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(sources))
+
+	s, err := c.Get("alchemy.io/core/readonly", "Source", "01-directory", "default")
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Contains(t, fmt.Sprintf("%v", s.Spec), "alchemy.io/v1alpha/CodeTemplate/terraform/test-template (from 'embed://')")
+}
+
+func TestNewEnvSourceConflictsAcrossSources(t *testing.T) {
+	uFs := afero.NewMemMapFs()
+
+	err := uFs.Mkdir("embed/", 0755)
+	require.NoError(t, err)
+
+	err = afero.WriteFile(uFs, "embed/code-template.yaml", []byte(file1), 0644)
+	require.NoError(t, err)
+
+	err = PreloadEmbedFS(afero.NewIOFS(uFs))
+	require.NoError(t, err)
+
+	// the same resource from another source is a conflict unless overrides
+	// are allowed
+	dir := t.TempDir()
+	override := strings.ReplaceAll(file1, "5.72.1", "5.80.0")
+	err = os.WriteFile(filepath.Join(dir, "code-template.yaml"), []byte(override), 0644)
+	require.NoError(t, err)
+
+	c, err := New(logT, []string{"dir://" + dir}, nil, false)
+	require.NoError(t, err)
+
+	m, err := c.Get("alchemy.io/v1alpha", "CodeTemplate", "test-template", "terraform")
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.True(t, m.Status.HasErr())
+	assert.False(t, m.Status.GetCondition(core.ResourceReady))
+	assert.ErrorContains(t, m.Status.ToNativeErr(), "found in both source 'embed://' and 'dir://"+dir+"'")
+
+	s, err := c.Get("alchemy.io/core/readonly", "Source", "01-directory", "default")
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.NotContains(t, fmt.Sprintf("%v", s.Spec), "(from 'embed://')")
+}
@@ -0,0 +1,121 @@
+package environment
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/system"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// Assets returns the fs rooted at the directory of the manifest file of the
+// resource within its source, which serves the files copied by code
+// templates.
+func (e *Env) Assets(b core.Base) (fs.FS, error) {
+	source, ok := e.sources[b.GetSource()]
+	if !ok {
+		return nil, fmt.Errorf("manifest source '%s' of resource '%s' is not loaded", b.GetSource(), b.Metadata.Name)
+	}
+
+	return source.Assets(b.GetFilePath())
+}
+
+// loadSources loads manifests from the sources in order, and returns the
+// loaded manifests along with the readonly Source manifests that report
+// on each of the sources, and the loaded sources by their URI.
+//
+// The same resource found in multiple sources is a conflict and flagged as
+// error of the resource, unless overrides are allowed, where the resource
+// from the latter source overrides the ones from the former sources. The
+// same resource found twice within a single source is always a conflict.
+func loadSources(uris []string, allowedApis []string, allowOverrides bool, log *logrus.Entry) (manifests, sourceManifests []core.AbstractedManifest, sources map[string]*system.ManifestSource, err error) {
+	c := log.WithField("context", "init")
+
+	manifests = []core.AbstractedManifest{}
+	sourceManifests = []core.AbstractedManifest{}
+	sources = map[string]*system.ManifestSource{}
+
+	for order, uri := range uris {
+		source, err := system.NewManifestSource(uri, allowedApis, log)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		loaded, err := source.Load()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sources[uri] = source
+
+		spec := core.SourceSpec{
+			Order:     order,
+			URI:       uri,
+			Provider:  source.Provider.Name(),
+			Resources: []string{},
+			Overrides: []string{},
+		}
+
+		for _, m := range loaded {
+			id := resourceID(m.Base)
+
+			_, i, found := lo.FindIndexOf(manifests, func(existing core.AbstractedManifest) bool {
+				return resourceID(existing.Base) == id
+			})
+			if !found {
+				manifests = append(manifests, m)
+				spec.Resources = append(spec.Resources, id)
+
+				continue
+			}
+
+			existing := manifests[i]
+			switch {
+			case existing.GetSource() == uri:
+				m.Status.SetError(fmt.Errorf("duplicate resource %s found in both '%s' and '%s' of source '%s'",
+					id, existing.GetFilePath(), m.GetFilePath(), uri))
+			case !allowOverrides:
+				m.Status.SetError(fmt.Errorf("conflicting resource %s found in both source '%s' and '%s', "+
+					"set allowOverrides of the config (or ALCHEMY_ALLOW_OVERRIDES) to let the latter source take precedence", id, existing.GetSource(), uri))
+			default:
+				spec.Overrides = append(spec.Overrides, fmt.Sprintf("%s (from '%s')", id, existing.GetSource()))
+
+				c.WithField("resource", m.Base).Warnf("resource %s from '%s' is overridden by '%s'",
+					id, existing.GetFilePath(), m.GetFilePath())
+			}
+
+			manifests[i] = m
+			if !lo.Contains(spec.Resources, id) {
+				spec.Resources = append(spec.Resources, id)
+			}
+		}
+
+		sourceManifest, err := core.ConvertToAbstractedManifest(core.SourceManifest{
+			Base: core.Base{
+				APIVersion: "alchemy.io/core/readonly",
+				Kind:       "Source",
+				Metadata: core.Metadata{
+					Name:      fmt.Sprintf("%02d-%s", order, source.Provider.Name()),
+					Namespace: "default",
+				},
+			},
+			Spec: spec,
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sourceManifests = append(sourceManifests, *sourceManifest)
+
+		c.Infof("source '%s' loaded with %d resource(s)", uri, len(loaded))
+	}
+
+	return manifests, sourceManifests, sources, nil
+}
+
+// resourceID returns the identity of the resource in the form of
+// `<apiVersion>/<kind>/<namespace>/<name>`.
+func resourceID(b core.Base) string {
+	return fmt.Sprintf("%s/%s/%s/%s",
+		b.APIVersion, b.Kind, lo.CoalesceOrEmpty(b.Metadata.Namespace, "default"), b.Metadata.Name)
+}
@@ -0,0 +1,26 @@
+package formcreator
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+)
+
+// ConfirmOverwrite asks whether the existing file is to be overwritten.
+func ConfirmOverwrite(file string) (bool, error) {
+	var overwrite bool
+
+	confirmation := huh.NewConfirm().
+		Title(fmt.Sprintf("Overwrite '%s'", file)).
+		Description("The file already exists, it will be skipped otherwise.").
+		Value(&overwrite)
+
+	err := huh.NewForm(huh.NewGroup(confirmation)).
+		WithTheme(themeFP()).
+		Run()
+	if err != nil {
+		return false, err
+	}
+
+	return overwrite, nil
+}
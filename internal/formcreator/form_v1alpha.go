@@ -1,11 +1,16 @@
 package formcreator
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
 	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/samber/lo"
 	"github.com/sirupsen/logrus"
 )
 
@@ -21,7 +26,7 @@ func NewFormCreatorV1Alpha(log *logrus.Entry) (*v1alphaFormCreator, error) {
 
 func (p *v1alphaFormCreator) Run(m v1alpha.FormManifest) (*v1alpha.FormResultManifest, error) {
 	formResult, err := v1alpha.NewFormResult(
-		fmt.Sprintf("form-%s", m.Metadata.Name),
+		formResultName(m),
 		m.Metadata.Namespace,
 		m.Base,
 		make(map[string]any),
@@ -35,9 +40,7 @@ func (p *v1alphaFormCreator) Run(m v1alpha.FormManifest) (*v1alpha.FormResultMan
 		return nil, err
 	}
 
-	grp := []*huh.Group{
-		huh.NewGroup(fields...),
-	}
+	grp := p.groupFields(m, formResult, fields)
 
 	if m.Spec.ConfirmationRequired {
 		confirmation := huh.NewConfirm().
@@ -56,15 +59,43 @@ func (p *v1alphaFormCreator) Run(m v1alpha.FormManifest) (*v1alpha.FormResultMan
 		return nil, err
 	}
 
+	p.applyDefaults(m, formResult)
+
+	err = omitHiddenFields(m, formResult)
+	if err != nil {
+		return nil, err
+	}
+
 	formResult.Status.SetCondition(v1alpha.CodeTemplateConsumptionReady, true)
 
 	return formResult, nil
 }
 
-func (p *v1alphaFormCreator) initFieldsV1Alpha(m v1alpha.FormManifest, resultManifest *v1alpha.FormResultManifest) ([]huh.Field, error) {
-	fds := []huh.Field{}
+// formResultName returns the unique name of the form result by the time
+// of the run, so that the previous runs are kept in the persistent store.
+// The time is accurate to the millisecond and followed by a random suffix,
+// so that the runs of the same time never overwrite each other.
+func formResultName(m v1alpha.FormManifest) string {
+	now := time.Now()
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+
+	return fmt.Sprintf("form-%s-%s-%03d-%s",
+		m.Metadata.Name, now.Format("20060102-150405"), now.Nanosecond()/int(time.Millisecond), hex.EncodeToString(suffix))
+}
+
+// formField is the huh field of the form field, along with the huh fields
+// of its nested fields, which are of the only item of object, or of every
+// item of array up to its maximum items.
+type formField struct {
+	field huh.Field
+	items [][]huh.Field
+}
 
-	for _, form := range m.Spec.Fields {
+func (p *v1alphaFormCreator) initFieldsV1Alpha(m v1alpha.FormManifest, resultManifest *v1alpha.FormResultManifest) ([]formField, error) {
+	fds := []formField{}
+
+	for i, form := range m.Spec.Fields {
 		entry := p.log.
 			WithFields(
 				logrus.Fields{
@@ -73,216 +104,433 @@ func (p *v1alphaFormCreator) initFieldsV1Alpha(m v1alpha.FormManifest, resultMan
 				},
 			)
 
-		switch form.InputType {
-		case v1alpha.TextInputType:
-			var value string
-			resultManifest.Spec.NewEmptyResult(form.Name, &value, form.InputType)
+		var fd formField
+		var err error
+		if v1alpha.IsStructured(form.InputType) {
+			fd, err = p.newStructuredField(form, resultManifest)
+		} else {
+			fd.field, err = p.newField(form, resultManifest,
+				func(value any) {
+					resultManifest.Spec.NewEmptyResult(form.Name, value, form.InputType)
+				},
+				func() (any, bool) {
+					return p.defaultOf(m, i, resultManifest)
+				},
+			)
+		}
+		if err != nil {
+			entry.WithError(err).Debug("unable to generate field for form group")
+			return nil, err
+		}
 
-			fd := huh.NewInput().
-				Title(form.Title).
-				Description(form.Description).
-				Validate(func(s string) error {
-					f := p.validate(form, resultManifest)
+		fds = append(fds, fd)
 
-					return f(s)
-				}).
-				Value(&value)
+		entry.Debugf("generate field '%s' for manifest '%s' successful", form.Name, m.Metadata.Name)
+	}
 
-			fds = append(fds, fd)
+	return fds, nil
 
-		case v1alpha.NumericalInputType:
-			var value string
-			resultManifest.Spec.NewEmptyResult(form.Name, &value, form.InputType)
+}
 
-			fd := huh.NewInput().
-				Title(form.Title).
-				Description(form.Description).
-				Validate(func(s string) error {
-					floatVal, err := strconv.ParseFloat(s, 64)
-					if err != nil {
-						return err
-					}
+// newField returns the huh field of the form field, whose answer is kept
+// by register, and whose default is resolved by defaults.
+func (p *v1alphaFormCreator) newField(
+	form v1alpha.Field,
+	resultManifest *v1alpha.FormResultManifest,
+	register func(value any),
+	defaults func() (any, bool),
+) (huh.Field, error) {
+	switch form.InputType {
+	case v1alpha.TextInputType:
+		var value string
+		register(&value)
+
+		fd := huh.NewInput().
+			Title(form.Title).
+			Description(form.Description).
+			PlaceholderFunc(func() string {
+				return textOf(defaults)
+			}, resultManifest.Spec.Result).
+			Validate(func(s string) error {
+				if s == "" {
+					s = textOf(defaults)
+				}
 
-					f := p.validate(form, resultManifest)
+				f := p.validate(form, resultManifest)
 
-					return f(floatVal)
-				}).
-				Value(&value)
+				return f(s)
+			}).
+			Value(&value)
 
-			fds = append(fds, fd)
+		return fd, nil
 
-		case v1alpha.MultilineTextInputType:
-			var value string
-			resultManifest.Spec.NewEmptyResult(form.Name, &value, form.InputType)
+	case v1alpha.NumericalInputType:
+		var value string
+		register(&value)
 
-			fd := huh.NewText().
-				Title(form.Title).
-				Description(form.Description).
-				Validate(func(s string) error {
-					f := p.validate(form, resultManifest)
+		fd := huh.NewInput().
+			Title(form.Title).
+			Description(form.Description).
+			PlaceholderFunc(func() string {
+				return textOf(defaults)
+			}, resultManifest.Spec.Result).
+			Validate(func(s string) error {
+				if s == "" {
+					s = textOf(defaults)
+				}
 
-					return f(s)
-				}).
-				Value(&value)
+				floatVal, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return err
+				}
 
-			fds = append(fds, fd)
+				f := p.validate(form, resultManifest)
 
-		case v1alpha.SingleSelectTextInputType:
-			if len(form.Choices) < 2 {
-				return nil, fmt.Errorf("selection type forms must have at least 2 choices")
-			}
+				return f(floatVal)
+			}).
+			Value(&value)
 
-			opts := []huh.Option[string]{}
+		return fd, nil
 
-			for _, choice := range form.Choices {
-				c, ok := choice.(string)
-				if !ok {
-					return nil, fmt.Errorf("unable to assert type string for value `%v`", choice)
+	case v1alpha.MultilineTextInputType:
+		var value string
+		register(&value)
+
+		fd := huh.NewText().
+			Title(form.Title).
+			Description(form.Description).
+			PlaceholderFunc(func() string {
+				return textOf(defaults)
+			}, resultManifest.Spec.Result).
+			Validate(func(s string) error {
+				if s == "" {
+					s = textOf(defaults)
 				}
-				opts = append(opts, huh.NewOption(c, c))
+
+				f := p.validate(form, resultManifest)
+
+				return f(s)
+			}).
+			Value(&value)
+
+		return fd, nil
+
+	case v1alpha.SingleSelectTextInputType:
+		if len(form.Choices) < 2 {
+			return nil, fmt.Errorf("selection type forms must have at least 2 choices")
+		}
+
+		opts := []huh.Option[string]{}
+
+		for _, choice := range form.Choices {
+			c, ok := choice.(string)
+			if !ok {
+				return nil, fmt.Errorf("unable to assert type string for value `%v`", choice)
 			}
+			opts = append(opts, huh.NewOption(c, c))
+		}
+
+		var value string
+		register(&value)
+		if v, ok := p.selectedDefault(form, defaults); ok {
+			value = v.(string)
+		}
 
-			var value string
-			resultManifest.Spec.NewEmptyResult(form.Name, &value, form.InputType)
+		fd := huh.NewSelect[string]().
+			Title(form.Title).
+			Description(form.Description).
+			Validate(func(s string) error {
+				f := p.validate(form, resultManifest)
 
-			fd := huh.NewSelect[string]().
-				Title(form.Title).
-				Description(form.Description).
-				Validate(func(s string) error {
-					f := p.validate(form, resultManifest)
+				return f(s)
+			}).
+			Options(opts...).
+			Value(&value)
+
+		return fd, nil
+
+	case v1alpha.SingleSelectNumericalInputType:
+		if len(form.Choices) < 2 {
+			return nil, fmt.Errorf("selection type forms must have at least 2 choices")
+		}
 
-					return f(s)
-				}).
-				Options(opts...).
-				Value(&value)
+		opts := []huh.Option[float64]{}
 
-			fds = append(fds, fd)
+		for _, choice := range form.Choices {
+			rawString := fmt.Sprintf("%v", choice)
 
-		case v1alpha.SingleSelectNumericalInputType:
-			if len(form.Choices) < 2 {
-				return nil, fmt.Errorf("selection type forms must have at least 2 choices")
+			c, err := strconv.ParseFloat(rawString, 64)
+			if err != nil {
+				return nil, err
 			}
 
-			opts := []huh.Option[float64]{}
+			opts = append(opts, huh.NewOption(rawString, c))
+		}
 
-			for _, choice := range form.Choices {
-				rawString := fmt.Sprintf("%v", choice)
+		var value float64
+		register(&value)
+		if v, ok := p.selectedDefault(form, defaults); ok {
+			value = v.(float64)
+		}
 
-				c, err := strconv.ParseFloat(rawString, 64)
-				if err != nil {
-					return nil, err
-				}
+		fd := huh.NewSelect[float64]().
+			Title(form.Title).
+			Description(form.Description).
+			Validate(func(s float64) error {
+				f := p.validate(form, resultManifest)
+
+				return f(s)
+			}).
+			Options(opts...).
+			Value(&value)
 
-				opts = append(opts, huh.NewOption(rawString, c))
+		return fd, nil
+
+	case v1alpha.MultiSelectTextInputType:
+		if len(form.Choices) < 2 {
+			return nil, fmt.Errorf("selection type forms must have at least 2 choices")
+		}
+
+		opts := []huh.Option[string]{}
+
+		for _, choice := range form.Choices {
+			c, ok := choice.(string)
+			if !ok {
+				return nil, fmt.Errorf("unable to assert type string for value `%v`", choice)
 			}
+			opts = append(opts, huh.NewOption(c, c))
+		}
 
-			var value float64
-			resultManifest.Spec.NewEmptyResult(form.Name, &value, form.InputType)
+		var value []string
+		register(&value)
+		if v, ok := p.selectedDefault(form, defaults); ok {
+			value = v.([]string)
+		}
 
-			fd := huh.NewSelect[float64]().
-				Title(form.Title).
-				Description(form.Description).
-				Validate(func(s float64) error {
-					f := p.validate(form, resultManifest)
+		fd := huh.NewMultiSelect[string]().
+			Title(form.Title).
+			Description(form.Description).
+			Validate(func(s []string) error {
+				f := p.validate(form, resultManifest)
 
-					return f(s)
-				}).
-				Options(opts...).
-				Value(&value)
+				return f(s)
+			}).
+			Options(opts...).
+			Value(&value)
 
-			fds = append(fds, fd)
+		return fd, nil
 
-		case v1alpha.MultiSelectTextInputType:
-			if len(form.Choices) < 2 {
-				return nil, fmt.Errorf("selection type forms must have at least 2 choices")
+	case v1alpha.MultiSelectNumericalInputType:
+		if len(form.Choices) < 2 {
+			return nil, fmt.Errorf("selection type forms must have at least 2 choices")
+		}
+
+		opts := []huh.Option[float64]{}
+
+		for _, choice := range form.Choices {
+			rawString := fmt.Sprintf("%f", choice)
+
+			c, err := strconv.ParseFloat(rawString, 64)
+			if err != nil {
+				return nil, err
 			}
 
-			opts := []huh.Option[string]{}
+			opts = append(opts, huh.NewOption(rawString, c))
+		}
+
+		var value []float64
+		register(&value)
+		if v, ok := p.selectedDefault(form, defaults); ok {
+			value = v.([]float64)
+		}
+
+		fd := huh.NewMultiSelect[float64]().
+			Title(form.Title).
+			Description(form.Description).
+			Validate(func(s []float64) error {
+				f := p.validate(form, resultManifest)
+
+				return f(s)
+			}).
+			Options(opts...).
+			Value(&value)
+
+		return fd, nil
+
+	case v1alpha.BooleanInputType:
 
-			for _, choice := range form.Choices {
-				c, ok := choice.(string)
-				if !ok {
-					return nil, fmt.Errorf("unable to assert type string for value `%v`", choice)
+		var value bool
+		register(&value)
+		if v, ok := p.selectedDefault(form, defaults); ok {
+			value = v.(bool)
+		}
+
+		fd := huh.NewSelect[bool]().Title(form.Title).
+			Description(form.Description).
+			Validate(func(s bool) error {
+				f := p.validate(form, resultManifest)
+
+				return f(s)
+			}).
+			Options(huh.NewOption("Yes", true), huh.NewOption("No", false)).
+			Value(&value)
+
+		return fd, nil
+
+	case v1alpha.IntegerInputType:
+		var value string
+		register(&value)
+
+		fd := huh.NewInput().
+			Title(form.Title).
+			Description(form.Description).
+			PlaceholderFunc(func() string {
+				return textOf(defaults)
+			}, resultManifest.Spec.Result).
+			Validate(func(s string) error {
+				if s == "" {
+					s = textOf(defaults)
 				}
-				opts = append(opts, huh.NewOption(c, c))
-			}
 
-			var value []string
-			resultManifest.Spec.NewEmptyResult(form.Name, &value, form.InputType)
+				intVal, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+				if err != nil {
+					return fmt.Errorf("value `%s` must be integer", s)
+				}
 
-			fd := huh.NewMultiSelect[string]().
-				Title(form.Title).
-				Description(form.Description).
-				Validate(func(s []string) error {
-					f := p.validate(form, resultManifest)
+				f := p.validate(form, resultManifest)
 
-					return f(s)
-				}).
-				Options(opts...).
-				Value(&value)
+				return f(intVal)
+			}).
+			Value(&value)
 
-			fds = append(fds, fd)
+		return fd, nil
 
-		case v1alpha.MultiSelectNumericalInputType:
-			if len(form.Choices) < 2 {
-				return nil, fmt.Errorf("selection type forms must have at least 2 choices")
-			}
+	case v1alpha.DateInputType:
+		var value string
+		register(&value)
 
-			opts := []huh.Option[float64]{}
+		fd := huh.NewInput().
+			Title(form.Title).
+			Description(form.Description).
+			PlaceholderFunc(func() string {
+				if s := textOf(defaults); s != "" {
+					return s
+				}
 
-			for _, choice := range form.Choices {
-				rawString := fmt.Sprintf("%f", choice)
+				return "YYYY-MM-DD"
+			}, resultManifest.Spec.Result).
+			Validate(func(s string) error {
+				if s == "" {
+					s = textOf(defaults)
+				}
 
-				c, err := strconv.ParseFloat(rawString, 64)
+				_, err := v1alpha.ParseDate(s)
 				if err != nil {
-					return nil, err
+					return err
 				}
 
-				opts = append(opts, huh.NewOption(rawString, c))
-			}
+				f := p.validate(form, resultManifest)
 
-			var value []float64
-			resultManifest.Spec.NewEmptyResult(form.Name, &value, form.InputType)
+				return f(strings.TrimSpace(s))
+			}).
+			Value(&value)
 
-			fd := huh.NewMultiSelect[float64]().
-				Title(form.Title).
-				Description(form.Description).
-				Validate(func(s []float64) error {
-					f := p.validate(form, resultManifest)
+		return fd, nil
 
-					return f(s)
-				}).
-				Options(opts...).
-				Value(&value)
+	case v1alpha.SecretInputType:
+		var value string
+		register(&value)
+		if v, ok := p.selectedDefault(form, defaults); ok {
+			value = v.(string)
+		}
 
-			fds = append(fds, fd)
+		fd := huh.NewInput().
+			Title(form.Title).
+			Description(form.Description).
+			EchoMode(huh.EchoModePassword).
+			Validate(func(s string) error {
+				f := p.validate(form, resultManifest)
 
-		case v1alpha.BooleanInputType:
+				return f(s)
+			}).
+			Value(&value)
 
-			var value bool
-			resultManifest.Spec.NewEmptyResult(form.Name, &value, form.InputType)
+		return fd, nil
 
-			fd := huh.NewSelect[bool]().Title(form.Title).
-				Description(form.Description).
-				Validate(func(s bool) error {
-					f := p.validate(form, resultManifest)
+	case v1alpha.FilePathInputType:
+		var value string
+		register(&value)
+		if v, ok := p.selectedDefault(form, defaults); ok {
+			value = v.(string)
+		}
 
-					return f(s)
-				}).
-				Options(huh.NewOption("Yes", true), huh.NewOption("No", false)).
-				Value(&value)
+		fd := huh.NewFilePicker().
+			Title(form.Title).
+			Description(form.Description).
+			FileAllowed(true).
+			DirAllowed(false).
+			Validate(func(s string) error {
+				err := checkFilePath(s)
+				if err != nil {
+					return err
+				}
 
-			fds = append(fds, fd)
+				f := p.validate(form, resultManifest)
 
-		default:
-			err := fmt.Errorf("unsupported type '%s'", form.InputType)
-			entry.WithError(err).Debug("unable to generate field for form group")
-			return nil, err
+				return f(s)
+			}).
+			Value(&value)
+
+		return fd, nil
+
+	case v1alpha.ListInputType:
+		var value string
+		register(&value)
+		if v, ok := p.selectedDefault(form, defaults); ok {
+			value = strings.Join(v.([]string), "\n")
 		}
 
-		entry.Debugf("generate field '%s' for manifest '%s' successful", form.Name, m.Metadata.Name)
-	}
+		fd := huh.NewText().
+			Title(form.Title).
+			Description(form.Description).
+			Placeholder("one entry per line").
+			Validate(func(s string) error {
+				f := p.validate(form, resultManifest)
+
+				return f(v1alpha.ParseList(s))
+			}).
+			Value(&value)
+
+		return fd, nil
+
+	case v1alpha.KeyValueListInputType:
+		var value string
+		register(&value)
+		if v, ok := p.selectedDefault(form, defaults); ok {
+			value = strings.Join(lo.Map(v.([]map[string]string), func(entry map[string]string, _ int) string {
+				return entry["key"] + "=" + entry["value"]
+			}), "\n")
+		}
 
-	return fds, nil
+		fd := huh.NewText().
+			Title(form.Title).
+			Description(form.Description).
+			Placeholder("key=value, one entry per line").
+			Validate(func(s string) error {
+				entries, err := v1alpha.ParseKeyValueList(s)
+				if err != nil {
+					return err
+				}
+
+				f := p.validate(form, resultManifest)
+
+				return f(entries)
+			}).
+			Value(&value)
 
+		return fd, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported type '%s'", form.InputType)
+	}
 }
@@ -0,0 +1,174 @@
+package formcreator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/system"
+)
+
+// resolveDefault returns the default of the field, which is the first of
+// the environment (unless it is empty), the CEL expression over the
+// answers of the shown fields before it, and the static default that is
+// set. It reports whether the field has any default.
+func resolveDefault(index int, field v1alpha.Field, answers map[string]any) (any, bool, error) {
+	if field.DefaultFrom != nil {
+		var value string
+		switch {
+		case field.DefaultFrom.Env != "":
+			value = os.Getenv(field.DefaultFrom.Env)
+		case field.DefaultFrom.GitConfig != "":
+			value = gitConfig(field.DefaultFrom.GitConfig)
+		}
+
+		if value != "" {
+			return value, true, nil
+		}
+	}
+
+	if field.DefaultExpr != "" {
+		out, err := system.ExecuteCELOnForm(answers, field.DefaultExpr)
+		if err != nil {
+			return nil, false, core.NewPathError(fmt.Sprintf("spec.fields[%d].defaultExpr", index), err)
+		}
+
+		return celToNative(out), true, nil
+	}
+
+	if field.Default != nil {
+		return field.Default, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// gitConfig returns the value of the key of the git config, it is empty if
+// either git or the key is not found.
+func gitConfig(key string) string {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// celToNative unwraps the output of CEL expression, where list is unwrapped
+// element by element.
+func celToNative(out ref.Val) any {
+	value := out.Value()
+
+	list := reflect.ValueOf(value)
+	if list.Kind() != reflect.Slice {
+		return value
+	}
+
+	output := []any{}
+	for i := 0; i < list.Len(); i++ {
+		elem := list.Index(i).Interface()
+		if v, ok := elem.(ref.Val); ok {
+			elem = v.Value()
+		}
+		output = append(output, elem)
+	}
+
+	return output
+}
+
+// defaultOf returns the default of the field by the answers in progress,
+// the error is reported into the status of the result, as it cannot be
+// surfaced by the terminal form.
+func (p *v1alphaFormCreator) defaultOf(m v1alpha.FormManifest, index int, resultManifest *v1alpha.FormResultManifest) (any, bool) {
+	result, err := nativeResult(resultManifest)
+	if err != nil {
+		resultManifest.Status.SetError(err)
+
+		return nil, false
+	}
+
+	shown, err := shownFields(m.Spec, index, result)
+	if err != nil {
+		resultManifest.Status.SetError(err)
+
+		return nil, false
+	}
+
+	value, ok, err := resolveDefault(index, m.Spec.Fields[index], shown.answers)
+	if err != nil {
+		resultManifest.Status.SetError(err)
+		p.log.WithError(err).Debugf("unable to resolve default of field '%s'", m.Spec.Fields[index].Name)
+
+		return nil, false
+	}
+
+	return value, ok
+}
+
+// textDefault returns the default of the textual field as text, it is
+// empty if the field has no default.
+func (p *v1alphaFormCreator) textDefault(m v1alpha.FormManifest, index int, resultManifest *v1alpha.FormResultManifest) string {
+	return textOf(func() (any, bool) {
+		return p.defaultOf(m, index, resultManifest)
+	})
+}
+
+// textOf returns the default resolved by defaults as text, it is empty if
+// there is no default.
+func textOf(defaults func() (any, bool)) string {
+	value, ok := defaults()
+	if !ok {
+		return ""
+	}
+
+	s, err := toString(value)
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+// applyDefaults answers the blank textual fields, nested fields included,
+// with their defaults in order, as their defaults are shown as placeholder
+// only.
+func (p *v1alphaFormCreator) applyDefaults(m v1alpha.FormManifest, resultManifest *v1alpha.FormResultManifest) {
+	for i, field := range m.Spec.Fields {
+		if !v1alpha.IsTextual(field.InputType) {
+			continue
+		}
+
+		value, ok := resultManifest.Spec.Result[field.Name].(*string)
+		if !ok || *value != "" {
+			continue
+		}
+
+		*value = p.textDefault(m, i, resultManifest)
+	}
+
+	applyNestedDefaults(m, resultManifest)
+}
+
+// selectedDefault returns the default of the field in the type of its
+// input type, which is selected (or entered, for the field whose default
+// is not shown as placeholder) before the form is shown.
+func (p *v1alphaFormCreator) selectedDefault(field v1alpha.Field, defaults func() (any, bool)) (any, bool) {
+	raw, ok := defaults()
+	if !ok {
+		return nil, false
+	}
+
+	value, err := coerceValue(field, raw)
+	if err != nil {
+		p.log.WithError(err).Debugf("default of field '%s' is not selected", field.Name)
+
+		return nil, false
+	}
+
+	return value, true
+}
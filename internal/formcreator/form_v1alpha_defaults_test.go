@@ -0,0 +1,133 @@
+package formcreator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var defaultsFormT = v1alpha.FormManifest{
+	Base: core.Base{
+		APIVersion: "alchemy.io/v1alpha",
+		Kind:       "Form",
+		Metadata: core.Metadata{
+			Name:      "defaults",
+			Namespace: "default",
+		},
+	},
+	Spec: v1alpha.FormSpec{
+		Fields: []v1alpha.Field{
+			{Name: "name", InputType: v1alpha.TextInputType},
+			{Name: "namespace", InputType: v1alpha.TextInputType, DefaultExpr: `result.name + "-ns"`},
+			{Name: "port", InputType: v1alpha.SingleSelectNumericalInputType, Choices: []any{8080, 8443}, Default: uint64(8080)},
+			{Name: "probe", InputType: v1alpha.SingleSelectTextInputType, Choices: []any{"healthz", "readyz"}, Default: "healthz"},
+			{Name: "owner", InputType: v1alpha.TextInputType, DefaultFrom: &v1alpha.DefaultSource{Env: "ALCHEMY_TEST_OWNER"}, Default: "nobody"},
+		},
+	},
+}
+
+func TestRunWithValuesDefaults(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	t.Setenv("ALCHEMY_TEST_OWNER", "")
+	result, err := p.RunWithValues(defaultsFormT, map[string]any{"name": "app"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"name":      "app",
+		"namespace": "app-ns",
+		"port":      8080.0,
+		"probe":     "healthz",
+		"owner":     "nobody",
+	}, result.Spec.Result, "empty environment variable must fall back")
+
+	t.Setenv("ALCHEMY_TEST_OWNER", "platform")
+	result, err = p.RunWithValues(defaultsFormT, map[string]any{"name": "app", "namespace": "prod", "port": 8443})
+	require.NoError(t, err)
+	assert.Equal(t, "prod", result.Spec.Result["namespace"], "value must take precedence over default")
+	assert.Equal(t, 8443.0, result.Spec.Result["port"])
+	assert.Equal(t, "platform", result.Spec.Result["owner"])
+
+	// dynamic default is validated as the value
+	_, err = p.RunWithValues(defaultsFormT, map[string]any{})
+	assert.ErrorContains(t, err, "at values.name: value is required")
+	assert.ErrorContains(t, err, "spec.fields[1].defaultExpr")
+}
+
+func TestResolveDefaultGitConfig(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	config := filepath.Join(t.TempDir(), ".gitconfig")
+	require.NoError(t, os.WriteFile(config, []byte("[alchemy]\n\towner = team-a\n"), 0644))
+	t.Setenv("GIT_CONFIG_GLOBAL", config)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	field := v1alpha.Field{Name: "owner", InputType: v1alpha.TextInputType, DefaultFrom: &v1alpha.DefaultSource{GitConfig: "alchemy.owner"}}
+	value, ok, err := resolveDefault(0, field, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "team-a", value)
+
+	field.DefaultFrom.GitConfig = "alchemy.missing"
+	_, ok, err = resolveDefault(0, field, nil)
+	require.NoError(t, err)
+	assert.False(t, ok, "missing git config without default must have no default")
+}
+
+func TestApplyDefaults(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	t.Setenv("ALCHEMY_TEST_OWNER", "")
+	r, err := v1alpha.NewFormResult("defaults", "default", defaultsFormT.Base, map[string]any{})
+	require.NoError(t, err)
+
+	_, err = p.initFieldsV1Alpha(defaultsFormT, r)
+	require.NoError(t, err)
+	assert.Equal(t, "healthz", *r.Spec.Result["probe"].(*string), "default must be selected")
+	assert.Equal(t, 8080.0, *r.Spec.Result["port"].(*float64), "default must be selected")
+
+	*r.Spec.Result["name"].(*string) = "app"
+	assert.Equal(t, "app-ns", p.textDefault(defaultsFormT, 1, r))
+
+	p.applyDefaults(defaultsFormT, r)
+	assert.Equal(t, "app-ns", *r.Spec.Result["namespace"].(*string))
+	assert.Equal(t, "nobody", *r.Spec.Result["owner"].(*string))
+}
+
+func TestValidateFieldDefaults(t *testing.T) {
+	form := defaultsFormT
+	form.Spec.Fields = []v1alpha.Field{
+		{Name: "name", InputType: v1alpha.TextInputType, Default: 1},
+		{Name: "replicas", InputType: v1alpha.NumericalInputType, Default: "two"},
+		{Name: "port", InputType: v1alpha.SingleSelectNumericalInputType, Choices: []any{8080, 8443}, Default: uint64(80)},
+		{Name: "protect", InputType: v1alpha.BooleanInputType, DefaultExpr: "true"},
+		{Name: "owner", InputType: v1alpha.TextInputType, DefaultFrom: &v1alpha.DefaultSource{}},
+		{Name: "ports", InputType: v1alpha.MultiSelectNumericalInputType, Choices: []any{8080, 8443}, Default: []any{uint64(8080)}},
+	}
+	for i := range form.Spec.Fields {
+		form.Spec.Fields[i].Title = form.Spec.Fields[i].Name
+		form.Spec.Fields[i].Description = form.Spec.Fields[i].Name
+	}
+
+	err := form.Validate()
+	require.Error(t, err)
+	for _, path := range []string{
+		"spec.forms[0].default",
+		"spec.forms[1].default",
+		"spec.forms[2].default",
+		"spec.forms[3].defaultExpr",
+		"spec.forms[4].defaultFrom",
+	} {
+		assert.ErrorContains(t, err, path)
+	}
+	assert.NotContains(t, err.Error(), "spec.forms[5]")
+}
@@ -0,0 +1,143 @@
+package formcreator
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/charmbracelet/huh"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/samber/lo"
+)
+
+// defaultMaxItems is the maximum items of array shown by the interactive
+// form, when the array is unbounded.
+const defaultMaxItems int = 10
+
+// newStructuredField returns the huh fields of object and array. The
+// object is led by the note of its title and description, whereas the
+// array is led by the selection of the number of its items, which are
+// answered up to its maximum items in advance, as the huh form cannot grow
+// while it runs.
+func (p *v1alphaFormCreator) newStructuredField(form v1alpha.Field, resultManifest *v1alpha.FormResultManifest) (formField, error) {
+	if form.InputType == v1alpha.ObjectInputType {
+		item := map[string]any{}
+		resultManifest.Spec.NewEmptyResult(form.Name, item, form.InputType)
+
+		fields, err := p.nestedFields(form, item, resultManifest)
+		if err != nil {
+			return formField{}, err
+		}
+
+		return formField{
+			field: huh.NewNote().Title(form.Title).Description(form.Description),
+			items: [][]huh.Field{fields},
+		}, nil
+	}
+
+	maxItems := form.MaxItems
+	if maxItems == 0 {
+		maxItems = max(form.MinItems, defaultMaxItems)
+	}
+
+	value := &v1alpha.ArrayResult{Count: form.MinItems}
+	items := [][]huh.Field{}
+	for k := 0; k < maxItems; k++ {
+		item := map[string]any{}
+		value.Items = append(value.Items, item)
+
+		fields, err := p.nestedFields(form, item, resultManifest)
+		if err != nil {
+			return formField{}, err
+		}
+		items = append(items, fields)
+	}
+	resultManifest.Spec.NewEmptyResult(form.Name, value, form.InputType)
+
+	opts := lo.Map(lo.RangeFrom(form.MinItems, maxItems-form.MinItems+1), func(n int, _ int) huh.Option[int] {
+		return huh.NewOption(strconv.Itoa(n), n)
+	})
+
+	fd := huh.NewSelect[int]().
+		Title(form.Title).
+		Description(fmt.Sprintf("%s (number of items)", form.Description)).
+		Options(opts...).
+		Value(&value.Count)
+
+	return formField{field: fd, items: items}, nil
+}
+
+// nestedFields returns the huh fields of the nested fields of the item,
+// which are hinted as `parent.field`.
+func (p *v1alphaFormCreator) nestedFields(
+	form v1alpha.Field, item map[string]any, resultManifest *v1alpha.FormResultManifest,
+) ([]huh.Field, error) {
+	fields := []huh.Field{}
+	for _, nested := range form.Fields {
+		fd, err := p.newField(nested, resultManifest,
+			func(value any) {
+				item[nested.Name] = value
+				resultManifest.Spec.TypeHintByResult[form.Name+"."+nested.Name] = nested.InputType
+			},
+			func() (any, bool) {
+				return nested.Default, nested.Default != nil
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, fd)
+	}
+
+	return fields, nil
+}
+
+// arrayCount returns the number of items of the array in progress.
+func arrayCount(resultManifest *v1alpha.FormResultManifest, name string) int {
+	value, ok := resultManifest.Spec.Result[name].(*v1alpha.ArrayResult)
+	if !ok {
+		return 0
+	}
+
+	return value.Count
+}
+
+// answeredItems returns the answers of the items of object and array in
+// progress, where only the items up to the number of items of array are
+// taken.
+func answeredItems(resultManifest *v1alpha.FormResultManifest, field v1alpha.Field) []map[string]any {
+	switch value := resultManifest.Spec.Result[field.Name].(type) {
+	case map[string]any:
+		return []map[string]any{value}
+	case *v1alpha.ArrayResult:
+		return value.Items[:min(max(value.Count, 0), len(value.Items))]
+	default:
+		return nil
+	}
+}
+
+// applyNestedDefaults answers the blank textual nested fields with their
+// static defaults.
+func applyNestedDefaults(m v1alpha.FormManifest, resultManifest *v1alpha.FormResultManifest) {
+	for _, field := range m.Spec.Fields {
+		if !v1alpha.IsStructured(field.InputType) {
+			continue
+		}
+
+		for _, item := range answeredItems(resultManifest, field) {
+			for _, nested := range field.Fields {
+				if !v1alpha.IsTextual(nested.InputType) || nested.Default == nil {
+					continue
+				}
+
+				value, ok := item[nested.Name].(*string)
+				if !ok || *value != "" {
+					continue
+				}
+
+				*value = textOf(func() (any, bool) {
+					return nested.Default, true
+				})
+			}
+		}
+	}
+}
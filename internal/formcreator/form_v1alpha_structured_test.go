@@ -0,0 +1,181 @@
+package formcreator
+
+import (
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var structuredFormT = v1alpha.FormManifest{
+	Base: core.Base{
+		APIVersion: "alchemy.io/v1alpha",
+		Kind:       "Form",
+		Metadata: core.Metadata{
+			Name:      "workload",
+			Namespace: "default",
+		},
+	},
+	Spec: v1alpha.FormSpec{
+		Fields: []v1alpha.Field{
+			{
+				Name:      "owner",
+				InputType: v1alpha.ObjectInputType,
+				Fields: []v1alpha.Field{
+					{Name: "team", InputType: v1alpha.TextInputType},
+					{Name: "email", InputType: v1alpha.TextInputType, Default: "team@example.com"},
+				},
+			},
+			{
+				Name:      "containers",
+				InputType: v1alpha.ArrayInputType,
+				MinItems:  1,
+				MaxItems:  3,
+				Fields: []v1alpha.Field{
+					{Name: "image", InputType: v1alpha.TextInputType},
+					{
+						Name:      "port",
+						InputType: v1alpha.IntegerInputType,
+						Default:   8080,
+						Constraint: &v1alpha.Constraint{
+							Cel: &v1alpha.Cel{
+								Expressions: []v1alpha.CelExpression{
+									{Value: "this > 1024", Message: "port must be unprivileged"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestRunWithValuesStructured(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	result, err := p.RunWithValues(structuredFormT, map[string]any{
+		"owner": map[string]any{"team": "platform"},
+		"containers": []any{
+			map[string]any{"image": "nginx:1.27"},
+			map[string]any{"image": "envoy:1.30", "port": uint64(9901)},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, result.Spec.ConvertResultToNative())
+
+	assert.Equal(t, map[string]any{"team": "platform", "email": "team@example.com"}, result.Spec.Result["owner"])
+	assert.Equal(t, []map[string]any{
+		{"image": "nginx:1.27", "port": int64(8080)},
+		{"image": "envoy:1.30", "port": int64(9901)},
+	}, result.Spec.Result["containers"])
+
+	// native results must be replayable
+	replayed, err := p.RunWithValues(structuredFormT, result.Spec.Result)
+	require.NoError(t, err)
+	assert.Equal(t, result.Spec.Result, replayed.Spec.Result)
+
+	_, err = p.RunWithValues(structuredFormT, map[string]any{
+		"owner": map[string]any{"unknown": "value"},
+		"containers": []any{
+			map[string]any{"port": "http"},
+			"nginx",
+		},
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "at values.owner.team: value is required")
+	assert.ErrorContains(t, err, "at values.owner.unknown")
+	assert.ErrorContains(t, err, "at values.containers[0].image: value is required")
+	assert.ErrorContains(t, err, "at values.containers[0].port: unable to convert value `http` into integer")
+	assert.ErrorContains(t, err, "at values.containers[1]: value `nginx` must be object")
+
+	_, err = p.RunWithValues(structuredFormT, map[string]any{
+		"owner": map[string]any{"team": "platform"},
+	})
+	assert.ErrorContains(t, err, "at values.containers: value must have at least 1 item(s)", "missing array must be empty")
+
+	_, err = p.RunWithValues(structuredFormT, map[string]any{
+		"owner": map[string]any{"team": "platform"},
+		"containers": []any{
+			map[string]any{"image": "nginx:1.27"},
+			map[string]any{"image": "nginx:1.27", "port": 80},
+		},
+	})
+	assert.ErrorContains(t, err, "at values.containers[1].port: port must be unprivileged")
+}
+
+func TestStructuredFields(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	r, err := v1alpha.NewFormResult("workload", "default", structuredFormT.Base, map[string]any{})
+	require.NoError(t, err)
+
+	fields, err := p.initFieldsV1Alpha(structuredFormT, r)
+	require.NoError(t, err)
+	require.Len(t, fields, 2)
+	assert.Len(t, fields[1].items, 3, "items must be answered up to maximum items")
+
+	// object and array of 3 items
+	assert.Len(t, p.groupFields(structuredFormT, r, fields), 5)
+
+	*r.Spec.Result["owner"].(map[string]any)["team"].(*string) = "platform"
+	array := r.Spec.Result["containers"].(*v1alpha.ArrayResult)
+	array.Count = 2
+	*array.Items[0]["image"].(*string) = "nginx:1.27"
+	*array.Items[1]["image"].(*string) = "envoy:1.30"
+	*array.Items[1]["port"].(*string) = "9901"
+	*array.Items[2]["image"].(*string) = "ignored"
+
+	p.applyDefaults(structuredFormT, r)
+	require.NoError(t, r.Spec.ConvertResultToNative())
+
+	assert.Equal(t, map[string]any{
+		"owner": map[string]any{"team": "platform", "email": "team@example.com"},
+		"containers": []map[string]any{
+			{"image": "nginx:1.27", "port": int64(8080)},
+			{"image": "envoy:1.30", "port": int64(9901)},
+		},
+	}, r.Spec.Result, "items beyond the number of items must be dropped")
+}
+
+func TestValidateNestedFields(t *testing.T) {
+	form := structuredFormT
+	form.Spec.Fields = []v1alpha.Field{
+		{Name: "owner", InputType: v1alpha.ObjectInputType},
+		{
+			Name:      "containers",
+			InputType: v1alpha.ArrayInputType,
+			MinItems:  3,
+			MaxItems:  1,
+			Default:   []any{},
+			Fields: []v1alpha.Field{
+				{Name: "image", Title: "Image", Description: "Image", InputType: v1alpha.TextInputType, ShowWhen: "true"},
+				{Name: "token", Title: "Token", Description: "Token", InputType: v1alpha.SecretInputType},
+				{Name: "image", Title: "Image", Description: "Image", InputType: v1alpha.TextInputType},
+			},
+		},
+		{Name: "name", InputType: v1alpha.TextInputType, MaxItems: 1},
+	}
+	for i := range form.Spec.Fields {
+		form.Spec.Fields[i].Title = form.Spec.Fields[i].Name
+		form.Spec.Fields[i].Description = form.Spec.Fields[i].Name
+	}
+
+	err := form.Validate()
+	require.Error(t, err)
+	for _, path := range []string{
+		"spec.forms[0].fields: inputType 'object' must have at least 1 field",
+		"spec.forms[1].maxItems",
+		"spec.forms[1].default",
+		"spec.forms[1].fields[0]: showWhen",
+		"spec.forms[1].fields[1].inputType",
+		"spec.forms[1].fields[2].name",
+		"spec.forms[2]: minItems and maxItems are not supported",
+	} {
+		assert.ErrorContains(t, err, path)
+	}
+}
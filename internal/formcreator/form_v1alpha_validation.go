@@ -3,7 +3,6 @@ package formcreator
 import (
 	"errors"
 
-	"github.com/mitchellh/copystructure"
 	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
 	"github.com/nicholastcs/alchemy/internal/system"
 )
@@ -50,26 +49,17 @@ func (p *v1alphaFormCreator) validate(
 	return func(input any) error {
 		// copying the struct as we don't want to mess with the
 		// struct under consumption by charmbracelet/huh API.
-		resultToValidate, err := copystructure.Copy(resultManifest.Spec)
-		if err != nil {
-			return err
-		}
-		r, ok := resultToValidate.(v1alpha.FormResultSpec)
-		if !ok {
-			return errors.New("unable to assert type to v1alpha.FormResultSpec")
-		}
-
-		err = r.ConvertResultToNative()
+		result, err := nativeResult(resultManifest)
 		if err != nil {
 			return err
 		}
 
 		valueUnderCheck := map[string]interface{}{
 			"this":   input,
-			"result": r.Result,
+			"result": result,
 		}
 
-		validationOutcome := p.validationHarness(valueUnderCheck, field)
+		validationOutcome := p.validationHarness(valueUnderCheck, field, resultManifest.Spec.TypeHintByResult)
 		if validationOutcome.HasRuntimeError() {
 			resultManifest.Status.SetError(validationOutcome.RuntimeError)
 		}
@@ -78,8 +68,12 @@ func (p *v1alphaFormCreator) validate(
 	}
 }
 
-func (p *v1alphaFormCreator) validationHarness(valueUnderCheck map[string]any, form v1alpha.Field) *validationOutcome {
-	entry := p.log.WithField("input", valueUnderCheck)
+func (p *v1alphaFormCreator) validationHarness(
+	valueUnderCheck map[string]any,
+	form v1alpha.Field,
+	typeHints map[string]string,
+) *validationOutcome {
+	entry := p.log.WithField("input", redactInput(valueUnderCheck, form, typeHints))
 
 	var v validationOutcome
 
@@ -110,3 +104,21 @@ func (p *v1alphaFormCreator) validationHarness(valueUnderCheck map[string]any, f
 
 	return &v
 }
+
+// redactInput returns the copy of the value under check to be logged,
+// whereby the answers of the secret fields are redacted.
+func redactInput(valueUnderCheck map[string]any, form v1alpha.Field, typeHints map[string]string) map[string]any {
+	output := map[string]any{
+		"this":   valueUnderCheck["this"],
+		"result": valueUnderCheck["result"],
+	}
+
+	if form.InputType == v1alpha.SecretInputType {
+		output["this"] = v1alpha.RedactedValue
+	}
+	if result, ok := valueUnderCheck["result"].(map[string]any); ok {
+		output["result"] = v1alpha.RedactResult(result, typeHints)
+	}
+
+	return output
+}
@@ -0,0 +1,574 @@
+package formcreator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/mitchellh/copystructure"
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+var errValueRequired = errors.New("value is required")
+var errSecretRedacted = errors.New("value of secret is redacted from the stored answers, it must be supplied again")
+
+// LoadValues reads the values used by non-interactive runs. Values are
+// first read from the YAML file (if any), then overridden by `key=value`
+// pairs from the `--set` flag.
+func LoadValues(file string, sets []string) (map[string]any, error) {
+	values := map[string]any{}
+
+	if file != "" {
+		in, err := afero.ReadFile(afero.NewOsFs(), file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read values file '%s': %w", file, err)
+		}
+
+		err = yaml.Unmarshal(in, &values)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse values file '%s': %w", file, err)
+		}
+
+		// an empty file unmarshal into nil map
+		if values == nil {
+			values = map[string]any{}
+		}
+	}
+
+	for _, set := range sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid --set value '%s', it must be in the form of key=value", set)
+		}
+
+		values[strings.TrimSpace(key)] = value
+	}
+
+	return values, nil
+}
+
+// RunWithValues builds the form result directly from the supplied values
+// without running the terminal form, where the missing value falls back to
+// the default of its field. Every value is coerced into the type of its
+// field, then validated against the CEL constraints of the field.
+//
+// All errors are collected and scoped by the path of the value, so that
+// every invalid value is reported in one go.
+func (p *v1alphaFormCreator) RunWithValues(m v1alpha.FormManifest, values map[string]any) (*v1alpha.FormResultManifest, error) {
+	formResult, err := v1alpha.NewFormResult(
+		formResultName(m),
+		m.Metadata.Namespace,
+		m.Base,
+		make(map[string]any),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs error
+
+	// the visibility is evaluated once in order, where every field is
+	// shown by the values of the shown fields before it.
+	shown := newVisibility(m.Spec)
+
+	hidden := map[string]bool{}
+	for i, field := range m.Spec.Fields {
+		path := fmt.Sprintf("values.%s", field.Name)
+
+		ok, err := shown.show(i)
+		if err != nil {
+			errs = errors.Join(errs, core.NewPathError(path, err))
+
+			continue
+		}
+		if !ok {
+			hidden[field.Name] = true
+			if _, ok := values[field.Name]; ok {
+				p.log.Debugf("value of hidden field '%s' is ignored", field.Name)
+			}
+
+			continue
+		}
+
+		// the redacted secret, such as from the lock, is taken as missing
+		raw, ok := values[field.Name]
+		redacted := ok && field.InputType == v1alpha.SecretInputType && raw == v1alpha.RedactedValue
+		if redacted {
+			ok = false
+		}
+		if !ok {
+			raw, ok, err = resolveDefault(i, field, shown.answers)
+			if err != nil {
+				errs = errors.Join(errs, core.NewPathError(path, err))
+
+				continue
+			}
+		}
+		if !ok && redacted {
+			errs = errors.Join(errs, core.NewPathError(path, errSecretRedacted))
+
+			continue
+		}
+		if !ok && v1alpha.IsStructured(field.InputType) {
+			// the nested fields are either defaulted or required
+			raw, ok = emptyStructured(field), true
+		}
+		if !ok {
+			errs = errors.Join(errs, core.NewPathError(path, errValueRequired))
+
+			continue
+		}
+
+		var value any
+		if v1alpha.IsStructured(field.InputType) {
+			value, err = coerceStructured(path, field, raw)
+		} else {
+			value, err = coerceValue(field, raw)
+			if err != nil {
+				err = core.NewPathError(path, err)
+			}
+		}
+		if err != nil {
+			errs = errors.Join(errs, err)
+
+			continue
+		}
+
+		formResult.Spec.NewEmptyResult(field.Name, value, field.InputType)
+		shown.answer(field.Name, value)
+	}
+
+	for name := range values {
+		known := slices.ContainsFunc(m.Spec.Fields, func(f v1alpha.Field) bool {
+			return f.Name == name
+		})
+		if !known {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("values.%s", name),
+				fmt.Errorf("field '%s' is not defined in form '%s'", name, m.Metadata.Name)))
+		}
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	// validates against the native results, just like what the
+	// interactive form does on every keystroke.
+	resultToValidate, err := copystructure.Copy(formResult.Spec)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := resultToValidate.(v1alpha.FormResultSpec)
+	if !ok {
+		return nil, errors.New("unable to assert type to v1alpha.FormResultSpec")
+	}
+
+	err = r.ConvertResultToNative()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range m.Spec.Fields {
+		if hidden[field.Name] {
+			continue
+		}
+
+		errs = errors.Join(errs, p.validateValue(fmt.Sprintf("values.%s", field.Name), field, r.Result[field.Name], r, formResult))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	p.log.WithFields(logrus.Fields{
+		"formManifest": m.Base,
+	}).Debugf("form result for manifest '%s' is built from values", m.Metadata.Name)
+
+	formResult.Status.SetCondition(v1alpha.CodeTemplateConsumptionReady, true)
+
+	return formResult, nil
+}
+
+// validateValue validates the native value of the field against the CEL
+// constraints of the field, or of the nested fields for object and array.
+func (p *v1alphaFormCreator) validateValue(
+	path string, field v1alpha.Field, value any, r v1alpha.FormResultSpec, formResult *v1alpha.FormResultManifest,
+) error {
+	var errs error
+
+	switch field.InputType {
+	case v1alpha.ObjectInputType:
+		item, _ := value.(map[string]any)
+		for _, nested := range field.Fields {
+			errs = errors.Join(errs, p.validateValue(fmt.Sprintf("%s.%s", path, nested.Name), nested, item[nested.Name], r, formResult))
+		}
+
+	case v1alpha.ArrayInputType:
+		items, _ := value.([]map[string]any)
+		for k, item := range items {
+			for _, nested := range field.Fields {
+				errs = errors.Join(errs, p.validateValue(fmt.Sprintf("%s[%d].%s", path, k, nested.Name), nested, item[nested.Name], r, formResult))
+			}
+		}
+
+	default:
+		valueUnderCheck := map[string]interface{}{
+			"this":   value,
+			"result": r.Result,
+		}
+
+		validationOutcome := p.validationHarness(valueUnderCheck, field, r.TypeHintByResult)
+		if validationOutcome.HasRuntimeError() {
+			formResult.Status.SetError(validationOutcome.RuntimeError)
+		}
+
+		err := errors.Join(validationOutcome.RuntimeError, validationOutcome.UserDefinedError)
+		if err != nil {
+			errs = errors.Join(errs, core.NewPathError(path, err))
+		}
+	}
+
+	return errs
+}
+
+// emptyStructured returns the empty value of object or array, which is
+// taken when the value is missing.
+func emptyStructured(field v1alpha.Field) any {
+	if field.InputType == v1alpha.ArrayInputType {
+		return []any{}
+	}
+
+	return map[string]any{}
+}
+
+// coerceStructured converts raw value of object into the map of its nested
+// fields, and of array into the list of the maps, where the missing nested
+// value falls back to the default of its field. The errors are scoped by
+// the path of the nested value, such as `values.containers[0].port`.
+func coerceStructured(path string, field v1alpha.Field, raw any) (any, error) {
+	if field.InputType == v1alpha.ObjectInputType {
+		return coerceObject(path, field, raw)
+	}
+
+	var list []any
+	switch v := raw.(type) {
+	case []any:
+		list = v
+	case []map[string]any:
+		list = lo.ToAnySlice(v)
+	default:
+		return nil, core.NewPathError(path, fmt.Errorf("value `%v` must be list", raw))
+	}
+
+	if len(list) < field.MinItems {
+		return nil, core.NewPathError(path, fmt.Errorf("value must have at least %d item(s)", field.MinItems))
+	}
+	if field.MaxItems > 0 && len(list) > field.MaxItems {
+		return nil, core.NewPathError(path, fmt.Errorf("value must have at most %d item(s)", field.MaxItems))
+	}
+
+	var errs error
+	output := []map[string]any{}
+	for k, item := range list {
+		value, err := coerceObject(fmt.Sprintf("%s[%d]", path, k), field, item)
+		if err != nil {
+			errs = errors.Join(errs, err)
+
+			continue
+		}
+		output = append(output, value)
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return output, nil
+}
+
+// coerceObject converts raw value into the map of the nested fields of the
+// field.
+func coerceObject(path string, field v1alpha.Field, raw any) (map[string]any, error) {
+	values, ok := raw.(map[string]any)
+	if !ok {
+		return nil, core.NewPathError(path, fmt.Errorf("value `%v` must be object", raw))
+	}
+
+	var errs error
+	output := map[string]any{}
+	for _, nested := range field.Fields {
+		nestedPath := fmt.Sprintf("%s.%s", path, nested.Name)
+
+		value, ok := values[nested.Name]
+		if !ok {
+			value, ok = nested.Default, nested.Default != nil
+		}
+		if !ok {
+			errs = errors.Join(errs, core.NewPathError(nestedPath, errValueRequired))
+
+			continue
+		}
+
+		v, err := coerceValue(nested, value)
+		if err != nil {
+			errs = errors.Join(errs, core.NewPathError(nestedPath, err))
+
+			continue
+		}
+		output[nested.Name] = v
+	}
+
+	for name := range values {
+		known := slices.ContainsFunc(field.Fields, func(f v1alpha.Field) bool {
+			return f.Name == name
+		})
+		if !known {
+			errs = errors.Join(errs, core.NewPathError(fmt.Sprintf("%s.%s", path, name),
+				fmt.Errorf("field '%s' is not defined in field '%s'", name, field.Name)))
+		}
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return output, nil
+}
+
+// coerceValue converts raw value, which is either typed from the values
+// file or a string from the `--set` flag, into the type expected by the
+// input type of the field.
+func coerceValue(field v1alpha.Field, raw any) (any, error) {
+	switch field.InputType {
+	case v1alpha.TextInputType, v1alpha.MultilineTextInputType, v1alpha.SecretInputType:
+		return toString(raw)
+
+	case v1alpha.NumericalInputType:
+		return toFloat(raw)
+
+	case v1alpha.IntegerInputType:
+		return toInteger(raw)
+
+	case v1alpha.DateInputType:
+		s, err := toString(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = v1alpha.ParseDate(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.TrimSpace(s), nil
+
+	case v1alpha.FilePathInputType:
+		s, err := toString(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return s, checkFilePath(s)
+
+	case v1alpha.ListInputType:
+		if s, ok := raw.(string); ok && strings.Contains(s, "\n") {
+			return v1alpha.ParseList(s), nil
+		}
+
+		output := []string{}
+		for _, item := range toList(raw) {
+			s, err := toString(item)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, s)
+		}
+
+		return output, nil
+
+	case v1alpha.KeyValueListInputType:
+		// entries from the `--set` flag are comma-separated
+		if s, ok := raw.(string); ok && !strings.Contains(s, "\n") {
+			raw = toList(s)
+		}
+
+		return v1alpha.ToKeyValueList(raw)
+
+	case v1alpha.BooleanInputType:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(v))
+		default:
+			return nil, fmt.Errorf("unable to convert value `%v` into boolean", raw)
+		}
+
+	case v1alpha.SingleSelectTextInputType:
+		s, err := toString(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return s, v1alpha.CheckChoice(field, s)
+
+	case v1alpha.SingleSelectNumericalInputType:
+		f, err := toFloat(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return f, v1alpha.CheckChoice(field, f)
+
+	case v1alpha.MultiSelectTextInputType:
+		output := []string{}
+		for _, item := range toList(raw) {
+			s, err := toString(item)
+			if err != nil {
+				return nil, err
+			}
+
+			err = v1alpha.CheckChoice(field, s)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, s)
+		}
+
+		return output, nil
+
+	case v1alpha.MultiSelectNumericalInputType:
+		output := []float64{}
+		for _, item := range toList(raw) {
+			f, err := toFloat(item)
+			if err != nil {
+				return nil, err
+			}
+
+			err = v1alpha.CheckChoice(field, f)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, f)
+		}
+
+		return output, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported type '%s'", field.InputType)
+	}
+}
+
+func toString(raw any) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprintf("%v", v), nil
+	default:
+		return "", fmt.Errorf("unable to convert value `%v` into string", raw)
+	}
+}
+
+func toFloat(raw any) (float64, error) {
+	switch v := raw.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to convert value `%v` into number", raw)
+		}
+
+		return f, nil
+	default:
+		return 0, fmt.Errorf("unable to convert value `%v` into number", raw)
+	}
+}
+
+func toInteger(raw any) (int64, error) {
+	switch v := raw.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("value `%v` overflows integer", raw)
+		}
+
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("unable to convert value `%v` into integer", raw)
+		}
+		if v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("value `%v` overflows integer", raw)
+		}
+
+		return int64(v), nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to convert value `%v` into integer", raw)
+		}
+
+		return i, nil
+	default:
+		return 0, fmt.Errorf("unable to convert value `%v` into integer", raw)
+	}
+}
+
+// checkFilePath checks whether the file of the path exists, which is
+// relative to the current directory.
+func checkFilePath(s string) error {
+	info, err := os.Stat(s)
+	if err != nil {
+		return fmt.Errorf("file '%s' does not exist", s)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("'%s' is a directory rather than a file", s)
+	}
+
+	return nil
+}
+
+// toList converts raw value into list, where comma-separated string from
+// the `--set` flag is split into items.
+func toList(raw any) []any {
+	switch v := raw.(type) {
+	case []any:
+		return v
+	case []string:
+		return lo.ToAnySlice(v)
+	case []float64:
+		return lo.ToAnySlice(v)
+	case string:
+		output := []any{}
+		for _, item := range strings.Split(v, ",") {
+			if strings.TrimSpace(item) == "" {
+				continue
+			}
+			output = append(output, strings.TrimSpace(item))
+		}
+
+		return output
+	default:
+		return []any{raw}
+	}
+}
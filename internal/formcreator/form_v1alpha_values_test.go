@@ -0,0 +1,341 @@
+package formcreator
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logT *logrus.Entry = utils.NewLogger()
+
+var formT = v1alpha.FormManifest{
+	Base: core.Base{
+		APIVersion: "alchemy.io/v1alpha",
+		Kind:       "Form",
+		Metadata: core.Metadata{
+			Name:      "app",
+			Namespace: "default",
+		},
+	},
+	Spec: v1alpha.FormSpec{
+		Fields: []v1alpha.Field{
+			{
+				Name:      "minimum_replicas",
+				InputType: v1alpha.NumericalInputType,
+				Constraint: &v1alpha.Constraint{
+					Cel: &v1alpha.Cel{
+						Expressions: []v1alpha.CelExpression{
+							{Value: "this > 0", Message: "minimum replicas must be greater than 0"},
+						},
+					},
+				},
+			},
+			{
+				Name:      "maximum_replicas",
+				InputType: v1alpha.NumericalInputType,
+				Constraint: &v1alpha.Constraint{
+					Cel: &v1alpha.Cel{
+						Expressions: []v1alpha.CelExpression{
+							{Value: "this >= result.minimum_replicas", Message: "maximum replicas must be greater or equal to than minimum replicas"},
+						},
+					},
+				},
+			},
+			{
+				Name:      "protect_app",
+				InputType: v1alpha.BooleanInputType,
+			},
+			{
+				Name:      "ports",
+				InputType: v1alpha.MultiSelectNumericalInputType,
+				Choices:   []any{8080, 8443},
+			},
+		},
+	},
+}
+
+func TestRunWithValues(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	result, err := p.RunWithValues(formT, map[string]any{
+		"minimum_replicas": "1",
+		"maximum_replicas": uint64(3),
+		"protect_app":      "true",
+		"ports":            "8080,8443",
+	})
+	require.NoError(t, err)
+
+	err = result.Spec.ConvertResultToNative()
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, result.Spec.Result["minimum_replicas"])
+	assert.Equal(t, 3.0, result.Spec.Result["maximum_replicas"])
+	assert.Equal(t, true, result.Spec.Result["protect_app"])
+	assert.Equal(t, []float64{8080, 8443}, result.Spec.Result["ports"])
+	assert.True(t, result.Status.GetCondition(v1alpha.CodeTemplateConsumptionReady))
+}
+
+func TestRunWithValuesPathScopedErrors(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	// missing, unknown and invalid choice values are reported together
+	_, err = p.RunWithValues(formT, map[string]any{
+		"minimum_replicas": 1,
+		"maximum_replicas": 3,
+		"ports":            "80",
+		"unknown":          "value",
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "at values.protect_app: value is required")
+	assert.ErrorContains(t, err, "at values.ports: value `80` is not one of the choices")
+	assert.ErrorContains(t, err, "at values.unknown")
+
+	// CEL constraints are evaluated after every value is coerced
+	_, err = p.RunWithValues(formT, map[string]any{
+		"minimum_replicas": 5,
+		"maximum_replicas": 3,
+		"protect_app":      false,
+		"ports":            []any{8080},
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "at values.maximum_replicas: maximum replicas must be greater or equal to than minimum replicas")}
+
+func TestRunWithValuesIntegerOverflow(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	form := formT
+	form.Spec.Fields = []v1alpha.Field{
+		{Name: "replicas", InputType: v1alpha.IntegerInputType},
+		{Name: "limit", InputType: v1alpha.IntegerInputType},
+	}
+
+	r, err := p.RunWithValues(form, map[string]any{"replicas": uint64(math.MaxInt64), "limit": -1e18})
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), r.Spec.Result["replicas"])
+
+	// integers beyond int64 are rejected rather than wrapped around
+	_, err = p.RunWithValues(form, map[string]any{"replicas": uint64(math.MaxInt64) + 1, "limit": 1e19})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "at values.replicas: value `9223372036854775808` overflows integer")
+	assert.ErrorContains(t, err, "at values.limit: value `1e+19` overflows integer")
+}
+
+func TestLoadValues(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "values.yaml")
+	err := os.WriteFile(file, []byte("name: app\nport: 8080\n"), 0644)
+	require.NoError(t, err)
+
+	values, err := LoadValues(file, []string{"name=override", "image=nginx:1.27"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "override", values["name"])
+	assert.Equal(t, "nginx:1.27", values["image"])
+	assert.EqualValues(t, 8080, values["port"])
+
+	_, err = LoadValues("", []string{"invalid"})
+	assert.Error(t, err)
+}
+
+func TestRunWithValuesReplaysPreviousResult(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	previous, err := p.RunWithValues(formT, map[string]any{
+		"minimum_replicas": 1.0,
+		"maximum_replicas": 3.0,
+		"protect_app":      true,
+		"ports":            []any{8080.0},
+	})
+	require.NoError(t, err)
+
+	err = previous.Spec.ConvertResultToNative()
+	require.NoError(t, err)
+
+	result, err := p.RunWithValues(formT, previous.Spec.Result)
+	require.NoError(t, err, "native results must be replayable")
+
+	err = result.Spec.ConvertResultToNative()
+	require.NoError(t, err)
+
+	assert.Equal(t, previous.Spec.Result, result.Spec.Result)
+}
+
+var typesFormT = v1alpha.FormManifest{
+	Base: formT.Base,
+	Spec: v1alpha.FormSpec{
+		Fields: []v1alpha.Field{
+			{
+				Name:      "replicas",
+				InputType: v1alpha.IntegerInputType,
+				Constraint: &v1alpha.Constraint{
+					Cel: &v1alpha.Cel{
+						Expressions: []v1alpha.CelExpression{
+							{Value: "this > 0", Message: "replicas must be greater than 0"},
+						},
+					},
+				},
+			},
+			{Name: "release_date", InputType: v1alpha.DateInputType},
+			{Name: "token", InputType: v1alpha.SecretInputType},
+			{Name: "manifest", InputType: v1alpha.FilePathInputType},
+			{Name: "hosts", InputType: v1alpha.ListInputType},
+			{
+				Name:      "env",
+				InputType: v1alpha.KeyValueListInputType,
+				Constraint: &v1alpha.Constraint{
+					Cel: &v1alpha.Cel{
+						Expressions: []v1alpha.CelExpression{
+							{Value: `this.all(e, e.key.matches("^[A-Z_]+$"))`, Message: "keys must be upper case"},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestRunWithValuesInputTypes(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	manifest := filepath.Join(t.TempDir(), "manifest.yaml")
+	require.NoError(t, os.WriteFile(manifest, []byte("kind: Test\n"), 0644))
+
+	result, err := p.RunWithValues(typesFormT, map[string]any{
+		"replicas":     "3",
+		"release_date": "2024-02-29",
+		"token":        "s3cr3t",
+		"manifest":     manifest,
+		"hosts":        "a.example.com,b.example.com",
+		"env":          "LOG_LEVEL=info,PORT=8080",
+	})
+	require.NoError(t, err)
+	require.NoError(t, result.Spec.ConvertResultToNative())
+
+	assert.Equal(t, int64(3), result.Spec.Result["replicas"], "integer must not be coerced into float")
+	assert.Equal(t, "2024-02-29", result.Spec.Result["release_date"])
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, result.Spec.Result["hosts"])
+	assert.Equal(t, []map[string]string{
+		{"key": "LOG_LEVEL", "value": "info"},
+		{"key": "PORT", "value": "8080"},
+	}, result.Spec.Result["env"])
+
+	// the values file has lists and maps
+	result, err = p.RunWithValues(typesFormT, map[string]any{
+		"replicas":     uint64(2),
+		"release_date": "2024-03-01",
+		"token":        "s3cr3t",
+		"manifest":     manifest,
+		"hosts":        []any{"a.example.com"},
+		"env":          map[string]any{"PORT": uint64(8080), "LOG_LEVEL": "info"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Spec.Result["replicas"])
+	assert.Equal(t, []map[string]string{
+		{"key": "LOG_LEVEL", "value": "info"},
+		{"key": "PORT", "value": "8080"},
+	}, result.Spec.Result["env"], "map must be in the order of its keys")
+
+	_, err = p.RunWithValues(typesFormT, map[string]any{
+		"replicas":     2.5,
+		"release_date": "2024-02-30",
+		"token":        "s3cr3t",
+		"manifest":     filepath.Join(t.TempDir(), "missing.yaml"),
+		"hosts":        []any{},
+		"env":          "LOG_LEVEL",
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "at values.replicas: unable to convert value `2.5` into integer")
+	assert.ErrorContains(t, err, "at values.release_date: value `2024-02-30` must be date")
+	assert.ErrorContains(t, err, "at values.manifest: file")
+	assert.ErrorContains(t, err, "at values.env: line 1: entry `LOG_LEVEL` must be in the form of key=value")
+
+	// CEL constraints are evaluated over the entries
+	_, err = p.RunWithValues(typesFormT, map[string]any{
+		"replicas":     1,
+		"release_date": "2024-03-01",
+		"token":        "s3cr3t",
+		"manifest":     manifest,
+		"hosts":        []any{},
+		"env":          []any{map[string]any{"key": "log_level", "value": "info"}},
+	})
+	assert.ErrorContains(t, err, "at values.env: keys must be upper case")
+}
+
+func TestRunWithValuesRedactedSecret(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	form := typesFormT
+	form.Spec.Fields = []v1alpha.Field{
+		{Name: "name", InputType: v1alpha.TextInputType},
+		{Name: "token", InputType: v1alpha.SecretInputType},
+	}
+
+	result, err := p.RunWithValues(form, map[string]any{"name": "app", "token": "s3cr3t"})
+	require.NoError(t, err)
+
+	redacted := result.Redacted()
+	assert.Equal(t, map[string]any{"name": "app", "token": v1alpha.RedactedValue}, redacted.Spec.Result)
+	assert.Equal(t, "s3cr3t", result.Spec.Result["token"], "answers must be left untouched")
+	assert.Equal(t, []string{"s3cr3t"}, result.Spec.Secrets())
+
+	// the redacted answers, such as from the lock, must supply the secret
+	// again
+	_, err = p.RunWithValues(form, redacted.Spec.Result)
+	assert.ErrorContains(t, err, "at values.token: value of secret is redacted")
+
+	form.Spec.Fields[1].DefaultFrom = &v1alpha.DefaultSource{Env: "ALCHEMY_TEST_TOKEN"}
+	t.Setenv("ALCHEMY_TEST_TOKEN", "from-env")
+	result, err = p.RunWithValues(form, redacted.Spec.Result)
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", result.Spec.Result["token"], "redacted secret must fall back to its default")
+}
+
+func TestConvertResultToNativeInputTypes(t *testing.T) {
+	replicas, date, hosts, env := "3", "2024-02-29", "a.example.com\n\n b.example.com \n", "LOG_LEVEL = info\nPORT=8080\n"
+
+	r, err := v1alpha.NewFormResult("types", "default", typesFormT.Base, map[string]any{})
+	require.NoError(t, err)
+	r.Spec.NewEmptyResult("replicas", &replicas, v1alpha.IntegerInputType)
+	r.Spec.NewEmptyResult("release_date", &date, v1alpha.DateInputType)
+	r.Spec.NewEmptyResult("hosts", &hosts, v1alpha.ListInputType)
+	r.Spec.NewEmptyResult("env", &env, v1alpha.KeyValueListInputType)
+
+	require.NoError(t, r.Spec.ConvertResultToNative())
+	assert.Equal(t, map[string]any{
+		"replicas":     int64(3),
+		"release_date": "2024-02-29",
+		"hosts":        []string{"a.example.com", "b.example.com"},
+		"env": []map[string]string{
+			{"key": "LOG_LEVEL", "value": "info"},
+			{"key": "PORT", "value": "8080"},
+		},
+	}, r.Spec.Result)
+
+	invalid := "PORT"
+	r.Spec.NewEmptyResult("env", &invalid, v1alpha.KeyValueListInputType)
+	assert.ErrorContains(t, r.Spec.ConvertResultToNative(), "line 1: entry `PORT` must be in the form of key=value")
+}
+
+func TestFormResultNameUnique(t *testing.T) {
+	names := map[string]bool{}
+	for range 100 {
+		name := formResultName(formT)
+		assert.Regexp(t, `^form-app-\d{8}-\d{6}-\d{3}-[0-9a-f]{6}$`, name)
+		assert.False(t, names[name], "form result name '%s' must be unique", name)
+
+		names[name] = true
+	}
+}
@@ -0,0 +1,311 @@
+package formcreator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+	"github.com/mitchellh/copystructure"
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/system"
+	"github.com/samber/lo"
+)
+
+// visibility is whether every field and group of the form is shown.
+type visibility struct {
+	spec    v1alpha.FormSpec
+	groupOf map[string]int
+
+	fields map[string]bool
+	groups map[int]bool
+
+	// answers are the answers of the shown fields.
+	answers map[string]any
+}
+
+// newVisibility returns the visibility of the form before any field is
+// evaluated.
+func newVisibility(spec v1alpha.FormSpec) *visibility {
+	groupOf := map[string]int{}
+	for i, group := range spec.Groups {
+		for _, name := range group.Fields {
+			groupOf[name] = i
+		}
+	}
+
+	return &visibility{
+		spec:    spec,
+		groupOf: groupOf,
+		fields:  map[string]bool{},
+		groups:  map[int]bool{},
+		answers: map[string]any{},
+	}
+}
+
+// show evaluates the showWhen of the field of the index, along with the
+// when of its group, against the answers of the shown fields before it.
+// The fields are evaluated in order, where the answer of the shown field
+// is taken by answer before the next field. The fields of the hidden group
+// are hidden.
+func (v *visibility) show(i int) (bool, error) {
+	field := v.spec.Fields[i]
+
+	if g, ok := v.groupOf[field.Name]; ok {
+		if _, evaluated := v.groups[g]; !evaluated {
+			v.groups[g] = true
+
+			when := v.spec.Groups[g].When
+			if when != "" {
+				ok, err := system.ExecuteCELOnFormCondition(v.answers, when)
+				if err != nil {
+					return false, core.NewPathError(fmt.Sprintf("spec.groups[%d].when", g), err)
+				}
+				v.groups[g] = ok
+			}
+		}
+
+		if !v.groups[g] {
+			v.fields[field.Name] = false
+
+			return false, nil
+		}
+	}
+
+	if field.ShowWhen != "" {
+		ok, err := system.ExecuteCELOnFormCondition(v.answers, field.ShowWhen)
+		if err != nil {
+			return false, core.NewPathError(fmt.Sprintf("spec.fields[%d].showWhen", i), err)
+		}
+		if !ok {
+			v.fields[field.Name] = false
+
+			return false, nil
+		}
+	}
+
+	v.fields[field.Name] = true
+
+	return true, nil
+}
+
+// answer takes the answer of the shown field, which the conditions of the
+// fields after it are evaluated against.
+func (v *visibility) answer(name string, value any) {
+	if v.fields[name] {
+		v.answers[name] = value
+	}
+}
+
+// shownFields evaluates the visibility of the first count fields of the
+// form in order, where the answers are taken from the result.
+func shownFields(spec v1alpha.FormSpec, count int, result map[string]any) (*visibility, error) {
+	shown := newVisibility(spec)
+
+	for i, field := range spec.Fields[:count] {
+		_, err := shown.show(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := result[field.Name]; ok {
+			shown.answer(field.Name, value)
+		}
+	}
+
+	return shown, nil
+}
+
+// nativeResult returns the copy of the answers in progress converted to
+// native, so that the struct under consumption by charmbracelet/huh API
+// is left untouched.
+func nativeResult(resultManifest *v1alpha.FormResultManifest) (map[string]any, error) {
+	resultToConvert, err := copystructure.Copy(resultManifest.Spec)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := resultToConvert.(v1alpha.FormResultSpec)
+	if !ok {
+		return nil, errors.New("unable to assert type to v1alpha.FormResultSpec")
+	}
+
+	err = r.ConvertResultToNative()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Result, nil
+}
+
+// omitHiddenFields removes the answers of the hidden fields from the form
+// result.
+func omitHiddenFields(m v1alpha.FormManifest, resultManifest *v1alpha.FormResultManifest) error {
+	result, err := nativeResult(resultManifest)
+	if err != nil {
+		return err
+	}
+
+	shown, err := shownFields(m.Spec, len(m.Spec.Fields), result)
+	if err != nil {
+		return err
+	}
+
+	for name, ok := range shown.fields {
+		if ok {
+			continue
+		}
+
+		delete(resultManifest.Spec.Result, name)
+		delete(resultManifest.Spec.TypeHintByResult, name)
+	}
+
+	return nil
+}
+
+// page is the page of the form, which is either the group of the form or
+// every field of the form if it has no group.
+type page struct {
+	index  int
+	group  *v1alpha.Group
+	fields []int
+}
+
+func formPages(spec v1alpha.FormSpec) []page {
+	if len(spec.Groups) == 0 {
+		return []page{{index: -1, fields: lo.Range(len(spec.Fields))}}
+	}
+
+	indexes := map[string]int{}
+	for i, field := range spec.Fields {
+		indexes[field.Name] = i
+	}
+
+	pages := []page{}
+	for i, group := range spec.Groups {
+		pages = append(pages, page{
+			index: i,
+			group: &spec.Groups[i],
+			fields: lo.Map(group.Fields, func(name string, _ int) int {
+				return indexes[name]
+			}),
+		})
+	}
+
+	return pages
+}
+
+// groupFields groups the huh fields of the form fields by the pages of
+// the form. Every field of showWhen is grouped on its own within its page,
+// so that it is hidden by the answers in progress, whereas the rest of the
+// fields are grouped along with the fields next to them. Every huh group
+// of the page carries the title and description of the page, and the note
+// of the page leads its first huh group. Object is grouped on its own
+// along with its nested fields, whereas every item of array is grouped on
+// its own after the array, which is hidden beyond the number of items.
+func (p *v1alphaFormCreator) groupFields(
+	m v1alpha.FormManifest, resultManifest *v1alpha.FormResultManifest, fields []formField,
+) []*huh.Group {
+	groups := []*huh.Group{}
+
+	for _, pg := range formPages(m.Spec) {
+		// hidden reports whether the huh group led by the field is hidden,
+		// or whether the page itself is hidden for the huh group of the
+		// note only.
+		hidden := func(leader int, byPage bool) func() bool {
+			return func() bool {
+				result, err := nativeResult(resultManifest)
+				if err != nil {
+					resultManifest.Status.SetError(err)
+
+					return false
+				}
+
+				shown, err := shownFields(m.Spec, leader+1, result)
+				if err != nil {
+					// the field is shown should its condition fail, the
+					// error is reported once the form is done.
+					resultManifest.Status.SetError(err)
+					p.log.WithError(err).Debug("unable to evaluate the condition of the form")
+
+					return false
+				}
+
+				if byPage {
+					return !shown.groups[pg.index]
+				}
+
+				return !shown.fields[m.Spec.Fields[leader].Name]
+			}
+		}
+
+		add := func(leader int, fds ...huh.Field) {
+			grp := huh.NewGroup(fds...)
+			if pg.group != nil {
+				grp = grp.Title(pg.group.Title).Description(pg.group.Description)
+			}
+
+			switch {
+			case leader < 0:
+				grp = grp.WithHideFunc(hidden(pg.fields[0], true))
+			case pg.group != nil || m.Spec.Fields[leader].ShowWhen != "":
+				grp = grp.WithHideFunc(hidden(leader, false))
+			}
+
+			groups = append(groups, grp)
+		}
+
+		pending := []huh.Field{}
+		pendingLeader := -1
+		if pg.group != nil && pg.group.Note != "" {
+			pending = append(pending, huh.NewNote().Description(pg.group.Note))
+		}
+
+		for _, i := range pg.fields {
+			field := m.Spec.Fields[i]
+
+			if field.ShowWhen == "" && !v1alpha.IsStructured(field.InputType) {
+				if pendingLeader < 0 {
+					pendingLeader = i
+				}
+				pending = append(pending, fields[i].field)
+
+				continue
+			}
+
+			if len(pending) > 0 {
+				add(pendingLeader, pending...)
+				pending = []huh.Field{}
+				pendingLeader = -1
+			}
+
+			switch field.InputType {
+			case v1alpha.ObjectInputType:
+				add(i, append([]huh.Field{fields[i].field}, fields[i].items[0]...)...)
+
+			case v1alpha.ArrayInputType:
+				add(i, fields[i].field)
+
+				for k, item := range fields[i].items {
+					fieldHidden := hidden(i, false)
+					grp := huh.NewGroup(item...).
+						Title(fmt.Sprintf("%s #%d", field.Title, k+1)).
+						Description(field.Description).
+						WithHideFunc(func() bool {
+							return k >= arrayCount(resultManifest, field.Name) || fieldHidden()
+						})
+
+					groups = append(groups, grp)
+				}
+
+			default:
+				add(i, fields[i].field)
+			}
+		}
+
+		if len(pending) > 0 {
+			add(pendingLeader, pending...)
+		}
+	}
+
+	return groups
+}
@@ -0,0 +1,224 @@
+package formcreator
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var scalingFormT = v1alpha.FormManifest{
+	Base: core.Base{
+		APIVersion: "alchemy.io/v1alpha",
+		Kind:       "Form",
+		Metadata: core.Metadata{
+			Name:      "scaling",
+			Namespace: "default",
+		},
+	},
+	Spec: v1alpha.FormSpec{
+		Fields: []v1alpha.Field{
+			{Name: "name", InputType: v1alpha.TextInputType},
+			{Name: "autoscaling", InputType: v1alpha.BooleanInputType},
+			{
+				Name:      "maximum_replicas",
+				InputType: v1alpha.NumericalInputType,
+				ShowWhen:  "result.autoscaling",
+				Constraint: &v1alpha.Constraint{
+					Cel: &v1alpha.Cel{
+						Expressions: []v1alpha.CelExpression{
+							{Value: "this > 1", Message: "maximum replicas must be greater than 1"},
+						},
+					},
+				},
+			},
+			{
+				Name:      "target_cpu",
+				InputType: v1alpha.NumericalInputType,
+				ShowWhen:  "has(result.maximum_replicas) && result.maximum_replicas > 2",
+			},
+		},
+	},
+}
+
+func TestShownFields(t *testing.T) {
+	shown, err := shownFields(scalingFormT.Spec, len(scalingFormT.Spec.Fields), map[string]any{
+		"name":             "app",
+		"autoscaling":      false,
+		"maximum_replicas": 5.0,
+		"target_cpu":       50.0,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		"name":             true,
+		"autoscaling":      true,
+		"maximum_replicas": false,
+		"target_cpu":       false,
+	}, shown.fields, "field must be hidden by the hidden field before it")
+
+	shown, err = shownFields(scalingFormT.Spec, len(scalingFormT.Spec.Fields), map[string]any{
+		"autoscaling":      true,
+		"maximum_replicas": 5.0,
+	})
+	require.NoError(t, err)
+	assert.True(t, shown.fields["maximum_replicas"])
+	assert.True(t, shown.fields["target_cpu"])
+
+	spec := v1alpha.FormSpec{Fields: []v1alpha.Field{{Name: "invalid", ShowWhen: "result.missing"}}}
+	_, err = shownFields(spec, 1, map[string]any{})
+	assert.ErrorContains(t, err, "spec.fields[0].showWhen")
+}
+
+func TestRunWithValuesShowWhen(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	// hidden fields are neither required nor validated, and their values
+	// are omitted from the result
+	result, err := p.RunWithValues(scalingFormT, map[string]any{
+		"name":             "app",
+		"autoscaling":      false,
+		"maximum_replicas": 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "app", "autoscaling": false}, result.Spec.Result)
+
+	result, err = p.RunWithValues(scalingFormT, map[string]any{
+		"name":             "app",
+		"autoscaling":      true,
+		"maximum_replicas": 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, result.Spec.Result["maximum_replicas"])
+	assert.NotContains(t, result.Spec.Result, "target_cpu")
+
+	_, err = p.RunWithValues(scalingFormT, map[string]any{
+		"name":             "app",
+		"autoscaling":      true,
+		"maximum_replicas": 3,
+	})
+	assert.ErrorContains(t, err, "at values.target_cpu: value is required", "shown field must be required")
+}
+
+func TestRunWithValuesShowWhenEvaluatedOnce(t *testing.T) {
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	m := scalingFormT
+	m.Spec.Fields = append([]v1alpha.Field{
+		{Name: "invalid", Title: "Invalid", Description: "Invalid", InputType: v1alpha.TextInputType, ShowWhen: "result.missing"},
+	}, scalingFormT.Spec.Fields...)
+
+	// the failed condition is reported by its field only, rather than
+	// evaluated again by every field after it
+	_, err = p.RunWithValues(m, map[string]any{
+		"name":        "app",
+		"autoscaling": false,
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, strings.Count(err.Error(), "spec.fields[0].showWhen"))
+	assert.ErrorContains(t, err, "at values.invalid")
+}
+
+func TestOmitHiddenFields(t *testing.T) {
+	autoscaling := false
+	maximumReplicas := "3"
+
+	r, err := v1alpha.NewFormResult("scaling", "default", scalingFormT.Base, map[string]any{})
+	require.NoError(t, err)
+	r.Spec.NewEmptyResult("autoscaling", &autoscaling, v1alpha.BooleanInputType)
+	r.Spec.NewEmptyResult("maximum_replicas", &maximumReplicas, v1alpha.NumericalInputType)
+
+	require.NoError(t, omitHiddenFields(scalingFormT, r))
+	assert.NotContains(t, r.Spec.Result, "maximum_replicas")
+	assert.NotContains(t, r.Spec.TypeHintByResult, "maximum_replicas")
+	assert.Contains(t, r.Spec.Result, "autoscaling")
+}
+
+func TestShownFieldsGroups(t *testing.T) {
+	spec := scalingFormT.Spec
+	spec.Fields = append(spec.Fields, v1alpha.Field{Name: "owner", InputType: v1alpha.TextInputType})
+	spec.Groups = []v1alpha.Group{
+		{Title: "General", Fields: []string{"name", "autoscaling"}},
+		{Title: "Scaling", When: "result.autoscaling", Fields: []string{"maximum_replicas", "target_cpu"}},
+		{Title: "Ownership", Fields: []string{"owner"}},
+	}
+
+	shown, err := shownFields(spec, len(spec.Fields), map[string]any{
+		"name":             "app",
+		"autoscaling":      false,
+		"maximum_replicas": 5.0,
+		"owner":            "team",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]bool{0: true, 1: false, 2: true}, shown.groups)
+	assert.False(t, shown.fields["maximum_replicas"], "field of hidden group must be hidden")
+	assert.True(t, shown.fields["owner"])
+
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	form := scalingFormT
+	form.Spec = spec
+	result, err := p.RunWithValues(form, map[string]any{
+		"name":        "app",
+		"autoscaling": false,
+		"owner":       "team",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "app", "autoscaling": false, "owner": "team"}, result.Spec.Result)
+}
+
+func TestGroupFields(t *testing.T) {
+	form := scalingFormT
+	form.Spec.Groups = []v1alpha.Group{
+		{Title: "General", Note: "**Welcome**", Fields: []string{"name", "autoscaling"}},
+		{Title: "Scaling", When: "result.autoscaling", Fields: []string{"maximum_replicas", "target_cpu"}},
+	}
+
+	p, err := NewFormCreatorV1Alpha(logT)
+	require.NoError(t, err)
+
+	r, err := v1alpha.NewFormResult("scaling", "default", form.Base, map[string]any{})
+	require.NoError(t, err)
+
+	fields, err := p.initFieldsV1Alpha(form, r)
+	require.NoError(t, err)
+
+	// general page, then a huh group of every field of showWhen
+	groups := p.groupFields(form, r, fields)
+	assert.Len(t, groups, 3)
+
+	form.Spec.Groups = nil
+	groups = p.groupFields(form, r, fields)
+	assert.Len(t, groups, 3, "fields without showWhen must be grouped together")
+}
+
+func TestValidateFormGroups(t *testing.T) {
+	form := scalingFormT
+	form.Spec.Fields = slices.Clone(form.Spec.Fields)
+	for i := range form.Spec.Fields {
+		form.Spec.Fields[i].Title = form.Spec.Fields[i].Name
+		form.Spec.Fields[i].Description = form.Spec.Fields[i].Name
+	}
+	form.Spec.Groups = []v1alpha.Group{
+		{Title: "", Fields: []string{"autoscaling", "name"}},
+		{Title: "Scaling", Fields: []string{"maximum_replicas", "unknown", "maximum_replicas"}},
+	}
+
+	err := form.Validate()
+	require.Error(t, err)
+	for _, path := range []string{
+		"spec.groups[0].title",
+		"spec.groups[0].fields[1]",
+		"spec.groups[1].fields[1]",
+		"spec.groups[1].fields[2]",
+		"spec.fields[3]",
+	} {
+		assert.ErrorContains(t, err, path)
+	}
+}
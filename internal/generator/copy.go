@@ -0,0 +1,134 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/system"
+	"github.com/samber/lo"
+)
+
+var errAssetsNotSupported = errors.New("files cannot be copied as the manifest source of the code template is unknown")
+
+// copyFiles reads the copy files of the code template from the directory
+// of its manifest file, where the file of templated copy file is rendered
+// with the result unless it is binary.
+func (g *v1alphaTemplateExecutor) copyFiles(
+	t *v1alpha.CodeTemplateManifest, engine templateEngine, result map[string]any,
+) ([]v1alpha.CodeTemplateStatusResult, error) {
+	if len(t.Spec.CopyFiles) == 0 {
+		return nil, nil
+	}
+
+	if g.assets == nil {
+		return nil, errAssetsNotSupported
+	}
+	assets, err := g.assets(t.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []v1alpha.CodeTemplateStatusResult{}
+	for i, c := range t.Spec.CopyFiles {
+		fieldPath := fmt.Sprintf("spec.copyFiles[%v]", i)
+
+		sources, err := listAssets(assets, c)
+		if err != nil {
+			return nil, core.NewPathError(fieldPath+".source", err)
+		}
+
+		for _, a := range sources {
+			src, dest := a.source, a.destination
+
+			b, err := fs.ReadFile(assets, src)
+			if err != nil {
+				return nil, core.NewPathError(fieldPath+".source", err)
+			}
+
+			code, encoding := v1alpha.EncodeContent(b)
+			if c.Template && encoding == "" {
+				code, err = engine.Render(fmt.Sprintf("alchemy-copy-%d-%s", i, src), code, result, nil)
+				if err != nil {
+					return nil, core.NewPathError(fieldPath, fmt.Errorf("unable to render '%s': %w", src, err))
+				}
+			}
+
+			output = append(output, v1alpha.CodeTemplateStatusResult{
+				File:     dest,
+				Code:     code,
+				Policy:   c.Policy,
+				Mode:     c.Mode,
+				Encoding: encoding,
+
+				// copied file is never removed even if it is empty
+				OnEmpty: v1alpha.OnEmptyKeep,
+			})
+
+			g.log.WithField("file", dest).Tracef("file '%s' copied from '%s'", dest, src)
+		}
+	}
+
+	return output, nil
+}
+
+type asset struct {
+	source      string
+	destination string
+}
+
+// listAssets lists the files of the copy file along with their
+// destination, where every file of the source directory included by the
+// glob patterns is listed recursively in lexical order.
+func listAssets(assets fs.FS, c v1alpha.CopyFile) ([]asset, error) {
+	src := path.Clean(c.Source)
+	dest := path.Clean(lo.CoalesceOrEmpty(c.Destination, c.Source))
+
+	info, err := fs.Stat(assets, src)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read '%s': %w", c.Source, err)
+	}
+	if !info.IsDir() {
+		return []asset{{source: src, destination: dest}}, nil
+	}
+
+	filter := system.ManifestFilter{
+		Include: lo.Ternary(len(c.Include) == 0, []string{"*"}, c.Include),
+		Exclude: c.Exclude,
+	}
+
+	output := []asset{}
+	err = fs.WalkDir(assets, src, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath == src {
+			return nil
+		}
+
+		relPath := strings.TrimPrefix(filePath, src+"/")
+		if d.IsDir() {
+			if filter.Excluded(relPath) {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+		if !filter.Included(relPath) {
+			return nil
+		}
+
+		output = append(output, asset{source: filePath, destination: path.Join(dest, relPath)})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
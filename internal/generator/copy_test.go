@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCopyFiles(t *testing.T) {
+	icon := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0xfe}
+
+	assets := fstest.MapFS{
+		"seed/README.md":         {Data: []byte("# {{ .name }}\n")},
+		"seed/assets/icon.png":   {Data: icon},
+		"seed/assets/notes.txt":  {Data: []byte("notes\n")},
+		"seed/drafts/draft.md":   {Data: []byte("draft\n")},
+		"scripts/deploy.sh":      {Data: []byte("#!/bin/sh\necho {{ .name }}\n")},
+		"code-template.yaml":     {Data: []byte("kind: CodeTemplate\n")},
+		"elsewhere/not-copied.x": {Data: []byte("x\n")},
+	}
+
+	r := &v1alpha.FormResultManifest{
+		Spec: v1alpha.FormResultSpec{
+			Result: map[string]any{"name": "app"},
+		},
+	}
+	r.Status.SetCondition(v1alpha.CodeTemplateConsumptionReady, true)
+
+	ct := &v1alpha.CodeTemplateManifest{
+		Spec: v1alpha.CodeTemplateSpec{
+			Kind: "go-template",
+			CopyFiles: []v1alpha.CopyFile{
+				{Source: "seed", Destination: "app", Exclude: []string{"drafts"}, Template: true},
+				{Source: "scripts/deploy.sh", Mode: "0755"},
+			},
+		},
+	}
+	ct.Status.SetCondition(core.ResourceReady, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	err = g.Generate(r, ct)
+	assert.ErrorIs(t, err, errAssetsNotSupported, "copy files must fail without assets")
+
+	g = g.WithAssets(func(core.Base) (fs.FS, error) {
+		return assets, nil
+	})
+	require.NoError(t, g.Generate(r, ct))
+
+	files := map[string]v1alpha.CodeTemplateStatusResult{}
+	for _, f := range ct.Status.GeneratedCodeFiles {
+		files[f.File] = f
+	}
+	assert.ElementsMatch(t,
+		[]string{"app/README.md", "app/assets/icon.png", "app/assets/notes.txt", "scripts/deploy.sh"},
+		lo.Keys(files))
+
+	assert.Equal(t, "# app\n", files["app/README.md"].Code)
+	assert.Equal(t, "#!/bin/sh\necho {{ .name }}\n", files["scripts/deploy.sh"].Code, "untemplated file must be copied as is")
+	assert.Equal(t, v1alpha.Base64Encoding, files["app/assets/icon.png"].Encoding)
+
+	dir := t.TempDir()
+	require.NoError(t, g.MakeFiles(dir, &ct.Status))
+
+	b, err := os.ReadFile(filepath.Join(dir, "app/assets/icon.png"))
+	require.NoError(t, err)
+	assert.Equal(t, icon, b, "binary file must be copied byte by byte")
+
+	info, err := os.Stat(filepath.Join(dir, "scripts/deploy.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	diffs, err := g.Diff(dir, &ct.Status)
+	require.NoError(t, err)
+	for _, d := range diffs {
+		assert.Equal(t, DiffUnchanged, d.Action, "'%s' must be unchanged once it is made", d.Path)
+	}
+}
@@ -0,0 +1,208 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+)
+
+const (
+	DiffCreated   string = "created"
+	DiffModified  string = "modified"
+	DiffDeleted   string = "deleted"
+	DiffUnchanged string = "unchanged"
+)
+
+// FileDiff is the change of the file that would be made by MakeFiles.
+type FileDiff struct {
+	Path   string
+	Action string
+
+	// Mode is the mode of the file after it is made, whereas CurrentMode
+	// is the mode of the existing file, which is zero if the file does not
+	// exist.
+	Mode        os.FileMode
+	CurrentMode os.FileMode
+
+	// UnifiedDiff is the unified diff between the existing file and the
+	// generated code, which is led by the change of mode if any. It is
+	// empty if the file is unchanged.
+	UnifiedDiff string
+}
+
+// Diff compares the generated code of the status against the existing
+// files of the directory without touching the filesystem, whereby the
+// policy of every file is respected.
+func (g *v1alphaTemplateExecutor) Diff(dir string, s *v1alpha.CodeTemplateStatus) ([]FileDiff, error) {
+	if !s.GetCondition(v1alpha.CodeTemplateConsumptionDone) {
+		return nil, errCodeTemplateConsumptionNotDone
+	}
+
+	fs := afero.NewOsFs()
+
+	output := []FileDiff{}
+	for _, f := range s.GeneratedCodeFiles {
+		target, err := sandboxPath(dir, f.File)
+		if err != nil {
+			return nil, err
+		}
+
+		current, exists, err := readFile(fs, target)
+		if err != nil {
+			return nil, err
+		}
+
+		action, err := g.resolveAction(f, exists, false)
+		if err != nil {
+			return nil, err
+		}
+
+		currentMode, _, err := currentFileMode(fs, target)
+		if err != nil {
+			return nil, err
+		}
+		mode := currentMode
+		if action == v1alpha.FileCreated || action == v1alpha.FileOverwritten || action == v1alpha.FileAppended {
+			mode, err = fileMode(fs, target, f.Mode)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		content, err := f.Content()
+		if err != nil {
+			return nil, err
+		}
+
+		// content of the file after it is made
+		var code string
+		switch action {
+		case v1alpha.FileCreated, v1alpha.FileOverwritten:
+			code = string(content)
+		case v1alpha.FileAppended:
+			code = current + string(content)
+		case v1alpha.FileSkipped, v1alpha.FileKept:
+			code = current
+		}
+
+		file := FileDiff{Path: f.File, Mode: mode, CurrentMode: currentMode}
+		switch {
+		case !exists && code == "":
+			// nothing is written, nor removed
+			continue
+		case !exists:
+			file.Action = DiffCreated
+		case action == v1alpha.FileDeleted:
+			file.Action = DiffDeleted
+			file.Mode = 0
+		case current == code && currentMode == mode:
+			file.Action = DiffUnchanged
+		default:
+			file.Action = DiffModified
+		}
+
+		file.UnifiedDiff, err = unifiedDiff(file, current, code)
+		if err != nil {
+			return nil, err
+		}
+
+		g.log.WithField("file", f.File).Debugf("file '%s' would be %s", f.File, file.Action)
+
+		output = append(output, file)
+	}
+
+	// patched files are diffed against their content once every patch is
+	// applied
+	lock, err := readLock(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := g.applyPatches(fs, dir, lock, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range patched {
+		file := FileDiff{Path: f.path, Action: DiffModified, Mode: f.mode, CurrentMode: f.mode}
+		if f.content == f.current {
+			file.Action = DiffUnchanged
+		}
+
+		file.UnifiedDiff, err = unifiedDiff(file, f.current, f.content)
+		if err != nil {
+			return nil, err
+		}
+
+		g.log.WithField("file", f.path).Debugf("file '%s' would be %s by patches", f.path, file.Action)
+
+		output = append(output, file)
+	}
+
+	return output, nil
+}
+
+// unifiedDiff returns the unified diff of the file between its current
+// content and the content after it is made, it is empty if the file is
+// unchanged.
+func unifiedDiff(file FileDiff, current string, code string) (string, error) {
+	if file.Action == DiffUnchanged {
+		return "", nil
+	}
+
+	fromFile, toFile := "a/"+file.Path, "b/"+file.Path
+	if file.Action == DiffCreated {
+		fromFile = "/dev/null"
+	}
+	if file.Action == DiffDeleted {
+		toFile = "/dev/null"
+	}
+
+	var output string
+	if isBinary(current) || isBinary(code) {
+		if current != code {
+			output = fmt.Sprintf("Binary files %s and %s differ\n", fromFile, toFile)
+		}
+	} else {
+		var err error
+		output, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        diffLines(current),
+			B:        diffLines(code),
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// the change of mode leads the diff, as git does
+	if file.Action == DiffModified && file.CurrentMode != file.Mode {
+		output = fmt.Sprintf("old mode %04o\nnew mode %04o\n", file.CurrentMode, file.Mode) + output
+	}
+
+	return output, nil
+}
+
+// isBinary reports whether the content is binary, which cannot be diffed
+// nor merged line by line.
+func isBinary(content string) bool {
+	_, encoding := v1alpha.EncodeContent([]byte(content))
+
+	return encoding != ""
+}
+
+// diffLines splits the content into lines for unified diff, where the line
+// break is added to the last line if it has none.
+func diffLines(s string) []string {
+	lines := splitLines(s)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		lines[len(lines)-1] += "\n"
+	}
+
+	return lines
+}
@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+
+	for file, content := range map[string]string{
+		"modified.txt":  "a\nb\n",
+		"unchanged.txt": "a\n",
+		"deleted.txt":   "a\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0644))
+	}
+
+	s := &v1alpha.CodeTemplateStatus{
+		GeneratedCodeFiles: []v1alpha.CodeTemplateStatusResult{
+			{File: "created.txt", Code: "a\n"},
+			{File: "modified.txt", Code: "a\nc\n"},
+			{File: "unchanged.txt", Code: "a\n"},
+			{File: "deleted.txt", Code: "  "},
+			{File: "skipped.txt", Code: ""},
+		},
+	}
+	s.SetCondition(v1alpha.CodeTemplateConsumptionDone, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	files, err := g.Diff(dir, s)
+	require.NoError(t, err)
+
+	actions := map[string]string{}
+	for _, f := range files {
+		actions[f.Path] = f.Action
+	}
+	assert.Equal(t, map[string]string{
+		"created.txt":   DiffCreated,
+		"modified.txt":  DiffModified,
+		"unchanged.txt": DiffUnchanged,
+		"deleted.txt":   DiffDeleted,
+	}, actions)
+
+	assert.Equal(t, "--- a/modified.txt\n+++ b/modified.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n", files[1].UnifiedDiff)
+	assert.Empty(t, files[2].UnifiedDiff, "unchanged file must have no diff")
+
+	_, err = os.Stat(filepath.Join(dir, "created.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "filesystem must not be touched")
+}
@@ -0,0 +1,59 @@
+package generator
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/dustin/go-humanize/english"
+	"github.com/samber/lo"
+)
+
+// templateEngine renders the templates of the code template, such as the
+// template and the file path of the generated file.
+type templateEngine interface {
+	// Render renders the template text by the answers of the form
+	// result along with the variables of the iteration, such as the
+	// element of forEach. The name identifies the template in the error.
+	Render(name string, text string, result map[string]any, vars map[string]any) (string, error)
+}
+
+// interpolator is the optional interface of the engine that renders the
+// text differently from the template when the output is a plain string,
+// such as the file path.
+type interpolator interface {
+	Interpolate(name string, text string, result map[string]any, vars map[string]any) (string, error)
+}
+
+// templateEngineFactory constructs the engine with the options of the code
+// template.
+type templateEngineFactory func(options []string) (templateEngine, error)
+
+// templateEngines are the engines by the template kind of the code
+// template, whereas their options are vetted by the validation of the code
+// template.
+var templateEngines = map[string]templateEngineFactory{
+	"go-template": newGoTemplateEngine,
+	"jinja":       newJinjaEngine,
+	"envsubst":    newEnvsubstEngine,
+	"cel-yaml":    newCELYAMLEngine,
+}
+
+// newTemplateEngine constructs the engine of the template kind.
+func newTemplateEngine(kind string, options []string) (templateEngine, error) {
+	factory, ok := templateEngines[kind]
+	if !ok {
+		kinds := lo.Keys(templateEngines)
+		slices.Sort(kinds)
+
+		return nil, fmt.Errorf("illegal template kind '%s', please select template kind under %s",
+			kind, english.OxfordWordSeries(kinds, "or"))
+	}
+
+	return factory(options)
+}
+
+// errInvalidOption returns the error of the option that is not supported
+// by the template kind.
+func errInvalidOption(kind string, option string) error {
+	return fmt.Errorf("invalid option '%s' of template kind '%s'", option, kind)
+}
@@ -0,0 +1,301 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/nicholastcs/alchemy/internal/system"
+)
+
+// celYAMLEngine renders the templates of YAML (or JSON) documents, where
+// the `${ ... }` placeholders of the keys and values are CEL expressions
+// over the answers of the form (`result`).
+//
+// The value that is a single placeholder is replaced by the typed output
+// of the expression, such as number, list or map, otherwise the outputs
+// are interpolated into the string. The documents are then encoded back,
+// thus the output is always quoted properly. `$${` escapes `${`.
+type celYAMLEngine struct {
+	json bool
+}
+
+func newCELYAMLEngine(options []string) (templateEngine, error) {
+	e := &celYAMLEngine{}
+	for _, opt := range options {
+		switch opt {
+		case "output=json":
+			e.json = true
+		default:
+			return nil, errInvalidOption("cel-yaml", opt)
+		}
+	}
+
+	return e, nil
+}
+
+func (e *celYAMLEngine) Render(name string, text string, result map[string]any, vars map[string]any) (string, error) {
+	documents := []string{}
+
+	// the documents are split by the lexer, as the decoder discards every
+	// document after an empty one, whereas the empty documents render
+	// nothing.
+	for _, in := range system.SplitDocuments([]byte(text)) {
+		if !system.HasContent(in) {
+			continue
+		}
+
+		var document any
+		err := yaml.UnmarshalWithOptions(in, &document, yaml.UseOrderedMap())
+		if err != nil {
+			return "", fmt.Errorf("unable to parse template '%s': %w", name, err)
+		}
+
+		output, err := e.evaluate(document, result, vars)
+		if err != nil {
+			return "", fmt.Errorf("unable to render template '%s': %w", name, err)
+		}
+
+		encoded, err := e.encode(output)
+		if err != nil {
+			return "", fmt.Errorf("unable to encode template '%s': %w", name, err)
+		}
+		documents = append(documents, encoded)
+	}
+
+	return strings.Join(documents, "---\n"), nil
+}
+
+// Interpolate renders the placeholders of the text as string, such as the
+// file path.
+func (e *celYAMLEngine) Interpolate(_ string, text string, result map[string]any, vars map[string]any) (string, error) {
+	output, err := interpolate(text, result, vars)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprint(output), nil
+}
+
+func (e *celYAMLEngine) encode(v any) (string, error) {
+	if !e.json {
+		b, err := yaml.MarshalWithOptions(v, yaml.IndentSequence(true), yaml.Indent(2))
+		if err != nil {
+			return "", err
+		}
+
+		return string(b), nil
+	}
+
+	b, err := yaml.MarshalWithOptions(v, yaml.JSON())
+	if err != nil {
+		return "", err
+	}
+
+	var indented bytes.Buffer
+	err = json.Indent(&indented, b, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(indented.String()) + "\n", nil
+}
+
+// evaluate evaluates the placeholders of the decoded document recursively.
+func (e *celYAMLEngine) evaluate(v any, result map[string]any, vars map[string]any) (any, error) {
+	switch value := v.(type) {
+	case yaml.MapSlice:
+		output := yaml.MapSlice{}
+		for _, item := range value {
+			key := item.Key
+			if s, ok := key.(string); ok {
+				k, err := interpolate(s, result, vars)
+				if err != nil {
+					return nil, err
+				}
+				key = fmt.Sprint(k)
+			}
+
+			v, err := e.evaluate(item.Value, result, vars)
+			if err != nil {
+				return nil, err
+			}
+
+			output = append(output, yaml.MapItem{Key: key, Value: v})
+		}
+
+		return output, nil
+	case []any:
+		output := []any{}
+		for _, item := range value {
+			v, err := e.evaluate(item, result, vars)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, v)
+		}
+
+		return output, nil
+	case string:
+		return interpolate(value, result, vars)
+	default:
+		return v, nil
+	}
+}
+
+// placeholder is the part of the text, which is either literal or CEL
+// expression.
+type placeholder struct {
+	text       string
+	expression bool
+}
+
+// interpolate evaluates the placeholders of the text. The typed output is
+// returned as is if the text is a single placeholder, otherwise the
+// outputs are interpolated into string.
+func interpolate(text string, result map[string]any, vars map[string]any) (any, error) {
+	parts, err := splitPlaceholders(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) == 1 && parts[0].expression {
+		return evaluateExpression(parts[0].text, result, vars)
+	}
+
+	var b strings.Builder
+	for _, part := range parts {
+		if !part.expression {
+			b.WriteString(part.text)
+
+			continue
+		}
+
+		output, err := evaluateExpression(part.text, result, vars)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(fmt.Sprint(output))
+	}
+
+	return b.String(), nil
+}
+
+func evaluateExpression(expression string, result map[string]any, vars map[string]any) (any, error) {
+	out, err := system.ExecuteCELOnResult(result, vars, expression)
+	if err != nil {
+		return nil, fmt.Errorf("expression '%s': %w", expression, err)
+	}
+
+	return celToNative(out), nil
+}
+
+// splitPlaceholders splits the text into literals and CEL expressions of
+// `${ ... }` placeholders, where the braces and quotes within the
+// expression are balanced.
+func splitPlaceholders(text string) ([]placeholder, error) {
+	parts := []placeholder{}
+
+	var literal strings.Builder
+	for i := 0; i < len(text); {
+		switch {
+		case strings.HasPrefix(text[i:], "$${"):
+			literal.WriteString("${")
+			i += 3
+		case strings.HasPrefix(text[i:], "${"):
+			end, err := placeholderEnd(text, i+2)
+			if err != nil {
+				return nil, err
+			}
+
+			if literal.Len() > 0 {
+				parts = append(parts, placeholder{text: literal.String()})
+				literal.Reset()
+			}
+			parts = append(parts, placeholder{text: strings.TrimSpace(text[i+2 : end]), expression: true})
+			i = end + 1
+		default:
+			literal.WriteByte(text[i])
+			i++
+		}
+	}
+	if literal.Len() > 0 || len(parts) == 0 {
+		parts = append(parts, placeholder{text: literal.String()})
+	}
+
+	return parts, nil
+}
+
+// placeholderEnd returns the index of the closing brace of the placeholder
+// started from the index.
+func placeholderEnd(text string, start int) (int, error) {
+	depth := 1
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			quote := text[i]
+			for i++; i < len(text) && text[i] != quote; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("placeholder '%s' is not closed", text[start-2:])
+}
+
+// celToNative converts the CEL value into the native value to be encoded,
+// where map is sorted by its keys. Whole number of double is converted to
+// integer, as numerical answers of the form are always double.
+func celToNative(v ref.Val) any {
+	switch value := v.(type) {
+	case types.Null:
+		return nil
+	case types.Double:
+		f := float64(value)
+		if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return int64(f)
+		}
+
+		return f
+	case traits.Mapper:
+		output := yaml.MapSlice{}
+		for it := value.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			output = append(output, yaml.MapItem{
+				Key:   fmt.Sprint(celToNative(key)),
+				Value: celToNative(value.Get(key)),
+			})
+		}
+		slices.SortFunc(output, func(a, b yaml.MapItem) int {
+			return strings.Compare(a.Key.(string), b.Key.(string))
+		})
+
+		return output
+	case traits.Lister:
+		output := []any{}
+		size, _ := value.Size().(types.Int)
+		for i := types.Int(0); i < size; i++ {
+			output = append(output, celToNative(value.Get(i)))
+		}
+
+		return output
+	default:
+		return v.Value()
+	}
+}
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/samber/lo"
+)
+
+// envsubstVariable matches `$$`, `${name}`, `${name:-default}`,
+// `${name-default}` and `$name`.
+var envsubstVariable = regexp.MustCompile(`\$\$|\$\{([a-zA-Z_][a-zA-Z0-9_]*)((:?-)([^}]*))?\}|\$([a-zA-Z_][a-zA-Z0-9_]*)`)
+
+// envsubstEngine substitutes the variables of the templates by the
+// answers like envsubst, where `$$` is substituted by `$`. Anything else,
+// such as `$1`, is retained as is.
+//
+// Just as the shell, the default of `${name:-default}` is substituted if the
+// variable is either unset or empty, whereas the default of
+// `${name-default}` is substituted only if the variable is unset.
+type envsubstEngine struct {
+	missingKeyError bool
+
+	// env falls back to the environment variables for the variables that
+	// are not answered.
+	env bool
+}
+
+func newEnvsubstEngine(options []string) (templateEngine, error) {
+	e := &envsubstEngine{}
+	for _, opt := range options {
+		switch opt {
+		case "missingkey=error":
+			e.missingKeyError = true
+		case "env":
+			e.env = true
+		default:
+			return nil, errInvalidOption("envsubst", opt)
+		}
+	}
+
+	return e, nil
+}
+
+func (e *envsubstEngine) Render(name string, text string, result map[string]any, vars map[string]any) (string, error) {
+	data := lo.Assign(result, vars)
+
+	var errs error
+
+	output := envsubstVariable.ReplaceAllStringFunc(text, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+
+		groups := envsubstVariable.FindStringSubmatch(match)
+		variable := groups[1] + groups[5]
+
+		value, ok := e.lookup(data, variable)
+		if ok && (value != "" || groups[3] != ":-") {
+			return value
+		}
+		if groups[2] != "" {
+			return groups[4]
+		}
+		if ok {
+			return value
+		}
+		if e.missingKeyError {
+			errs = errors.Join(errs, fmt.Errorf("variable '%s' of template '%s' is not set", variable, name))
+		}
+
+		return ""
+	})
+	if errs != nil {
+		return "", errs
+	}
+
+	return output, nil
+}
+
+// lookup returns the value of the variable by the answers, else by the
+// environment variables if it is enabled.
+func (e *envsubstEngine) lookup(data map[string]any, variable string) (string, bool) {
+	if v, ok := data[variable]; ok && v != nil {
+		return fmt.Sprint(v), true
+	}
+	if e.env {
+		return os.LookupEnv(variable)
+	}
+
+	return "", false
+}
@@ -0,0 +1,49 @@
+package generator
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/samber/lo"
+)
+
+// goTemplateEngine renders the templates with Go text/template, where
+// every template shares the same functions and options.
+type goTemplateEngine struct {
+	tmpl *template.Template
+}
+
+func newGoTemplateEngine(options []string) (templateEngine, error) {
+	tmpl := template.New("alchemy-main")
+
+	for _, opt := range options {
+		switch opt {
+		case "funcs=sprig":
+			tmpl = tmpl.Funcs(sprig.FuncMap())
+		case "missingkey=error":
+			tmpl = tmpl.Option("missingkey=error")
+		default:
+			return nil, errInvalidOption("go-template", opt)
+		}
+	}
+
+	return &goTemplateEngine{tmpl: tmpl}, nil
+}
+
+func (e *goTemplateEngine) Render(name string, text string, result map[string]any, vars map[string]any) (string, error) {
+	data := lo.Assign(result, vars)
+
+	output, err := e.tmpl.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	err = output.Execute(&b, data)
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/samber/lo"
+)
+
+// jinjaEngine renders the templates with Jinja-like syntax by pongo2,
+// whereby templates cannot load other files.
+type jinjaEngine struct {
+	set        *pongo2.TemplateSet
+	autoescape bool
+}
+
+func newJinjaEngine(options []string) (templateEngine, error) {
+	set := pongo2.NewSet("alchemy", pongo2.NewFSLoader(noFiles{}))
+
+	// tags that load other files are banned, as the templates could be
+	// loaded from remote sources.
+	for _, tag := range []string{"include", "import", "extends", "ssi"} {
+		err := set.BanTag(tag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	e := &jinjaEngine{set: set}
+	for _, opt := range options {
+		switch opt {
+		case "trim_blocks":
+			set.Options.TrimBlocks = true
+		case "lstrip_blocks":
+			set.Options.LStripBlocks = true
+		case "autoescape":
+			e.autoescape = true
+		default:
+			return nil, errInvalidOption("jinja", opt)
+		}
+	}
+
+	return e, nil
+}
+
+func (e *jinjaEngine) Render(name string, text string, result map[string]any, vars map[string]any) (string, error) {
+	data := lo.Assign(result, vars)
+
+	// the generated code is not HTML, escaping is opted in by option
+	// `autoescape` instead. It is set by the template rather than
+	// pongo2.SetAutoescape, which is global to the process, whereas the
+	// blank variables guard the template from trim_blocks and
+	// lstrip_blocks of the wrapping tags.
+	mode := "off"
+	if e.autoescape {
+		mode = "on"
+	}
+	text = "{% autoescape " + mode + ` %}{{ "" }}` + text + `{{ "" }}{% endautoescape %}`
+
+	tpl, err := e.set.FromString(text)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse template '%s': %w", name, err)
+	}
+
+	output, err := tpl.Execute(pongo2.Context(data))
+	if err != nil {
+		return "", fmt.Errorf("unable to render template '%s': %w", name, err)
+	}
+
+	return output, nil
+}
+
+// noFiles is the fs without any file.
+type noFiles struct{}
+
+func (noFiles) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
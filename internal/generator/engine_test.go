@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateEngines(t *testing.T) {
+	t.Setenv("ALCHEMY_TEST_REGION", "us-east-1")
+	t.Setenv("ALCHEMY_TEST_EMPTY", "")
+
+	data := map[string]any{
+		"name":     "app",
+		"replicas": 3,
+		"html":     "<b>",
+		"empty":    "",
+		"regions":  []string{"us-east-1", "eu-west-1"},
+	}
+
+	tests := []struct {
+		kind     string
+		options  []string
+		text     string
+		expected string
+		err      bool
+	}{
+		{kind: "go-template", text: "{{ .name }}: {{ .replicas }}", expected: "app: 3"},
+		{kind: "go-template", options: []string{"missingkey=error"}, text: "{{ .missing }}", err: true},
+		{kind: "go-template", options: []string{"funcs=sprig"}, text: "{{ .name | upper }}", expected: "APP"},
+		{kind: "jinja", text: "{{ name }}: {{ replicas }} {{ html }}", expected: "app: 3 <b>"},
+		{kind: "jinja", options: []string{"autoescape"}, text: "{{ html }}", expected: "&lt;b&gt;"},
+		{
+			kind:     "jinja",
+			options:  []string{"trim_blocks", "lstrip_blocks"},
+			text:     "{% for r in regions %}\n  {% if r != \"eu-west-1\" %}\n{{ r }}\n  {% endif %}\n{% endfor %}\n",
+			expected: "us-east-1\n",
+		},
+		{
+			// the leading and trailing whitespaces are kept as is
+			kind:     "jinja",
+			options:  []string{"trim_blocks", "lstrip_blocks", "autoescape"},
+			text:     "\n{{ name }}\n  ",
+			expected: "\napp\n  ",
+		},
+		{kind: "jinja", text: `{% include "/etc/passwd" %}`, err: true},
+		{kind: "envsubst", text: "name=$name replicas=${replicas} arg=$1 cost=$$5", expected: "name=app replicas=3 arg=$1 cost=$5"},
+		{kind: "envsubst", text: "${missing:-fallback} ${missing}", expected: "fallback "},
+		{kind: "envsubst", text: "[${empty:-fallback}] [${empty-fallback}] [${missing-fallback}] [${name-fallback}]", expected: "[fallback] [] [fallback] [app]"},
+		{kind: "envsubst", options: []string{"env"}, text: "[${ALCHEMY_TEST_EMPTY:-fallback}] [${ALCHEMY_TEST_EMPTY-fallback}]", expected: "[fallback] []"},
+		{kind: "envsubst", options: []string{"missingkey=error"}, text: "${empty}", expected: ""},
+		{kind: "envsubst", options: []string{"missingkey=error"}, text: "${missing}", err: true},
+		{kind: "envsubst", text: "$ALCHEMY_TEST_REGION", expected: ""},
+		{kind: "envsubst", options: []string{"env"}, text: "$ALCHEMY_TEST_REGION", expected: "us-east-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind+"/"+tt.text, func(t *testing.T) {
+			engine, err := newTemplateEngine(tt.kind, tt.options)
+			require.NoError(t, err)
+
+			output, err := engine.Render("test", tt.text, data, nil)
+			if tt.err {
+				assert.Error(t, err)
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+
+	_, err := newTemplateEngine("mustache", nil)
+	assert.ErrorContains(t, err, "illegal template kind 'mustache'")
+
+	_, err = newTemplateEngine("envsubst", []string{"funcs=sprig"})
+	assert.ErrorContains(t, err, "invalid option 'funcs=sprig'")
+}
+
+func TestCELYAMLEngine(t *testing.T) {
+	result := map[string]any{
+		"name":     "web: frontend",
+		"replicas": 3.0,
+		"ports":    []int{80, 443},
+		"labels":   map[string]any{"team": "platform", "tier": "yes"},
+	}
+
+	engine, err := newTemplateEngine("cel-yaml", nil)
+	require.NoError(t, err)
+
+	output, err := engine.Render("test", `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ${ result.name }
+  labels: ${ result.labels }
+  annotations:
+    ${ "alchemy.io/" + result.labels.team }: "replicas-${result.replicas}"
+    literal: $${ not evaluated }
+spec:
+  replicas: ${ result.replicas * 2.0 }
+  ports: "${ result.ports.map(p, {'port': p}) }"
+  enabled: ${ item }
+---
+kind: Service
+`, result, map[string]any{"item": true})
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: "web: frontend"
+  labels:
+    team: platform
+    tier: "yes"
+  annotations:
+    alchemy.io/platform: replicas-3
+    literal: ${ not evaluated }
+spec:
+  replicas: 6
+  ports:
+    - port: 80
+    - port: 443
+  enabled: true
+---
+kind: Service
+`, output)
+
+	engine, err = newTemplateEngine("cel-yaml", []string{"output=json"})
+	require.NoError(t, err)
+
+	output, err = engine.Render("test", `{"name": "${result.name}", "ports": "${result.ports}"}`, result, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"name\": \"web: frontend\",\n  \"ports\": [\n    80,\n    443\n  ]\n}\n", output)
+
+	file, err := engine.(interpolator).Interpolate("test", "k8s/${result.labels.team}.yaml", result, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "k8s/platform.yaml", file)
+
+	// the documents after the empty document are rendered
+	output, err = engine.Render("test", "---\n---\n# comment only\n---\nname: ${ result.labels.team }\n", result, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"name\": \"platform\"\n}\n", output)
+
+	_, err = engine.Render("test", "name: ${ result.name", result, nil)
+	assert.ErrorContains(t, err, "is not closed")
+
+	_, err = engine.Render("test", "name: ${ result.missing }", result, nil)
+	assert.Error(t, err)
+}
@@ -4,38 +4,86 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
-	"text/template"
 
-	"github.com/Masterminds/sprig/v3"
+	"github.com/dustin/go-humanize/english"
+	"github.com/goccy/go-yaml"
+	"github.com/google/cel-go/common/types/ref"
 	"github.com/nicholastcs/alchemy/internal/apis/core"
 	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/system"
 	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/samber/lo"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 )
 
 type v1alphaTemplateExecutor struct {
 	log *logrus.Entry
+
+	// defaultPolicy is the policy of the generated file that has no
+	// policy of its own.
+	defaultPolicy string
+
+	// prompt asks whether the existing file is to be overwritten, for
+	// the generated file of prompt policy.
+	prompt func(file string) (bool, error)
+
+	// assets returns the fs of the directory of the manifest file, which
+	// the files of the code template are copied from.
+	assets func(b core.Base) (fs.FS, error)
 }
 
 func NewExecutor(log *logrus.Entry) (*v1alphaTemplateExecutor, error) {
 	return &v1alphaTemplateExecutor{
-		log: log.WithField("context", "executor/v1alpha"),
+		log:           log.WithField("context", "executor/v1alpha"),
+		defaultPolicy: v1alpha.OverwritePolicy,
 	}, nil
 }
 
+// WithDefaultPolicy sets the policy of the generated file that has no
+// policy of its own.
+func (g *v1alphaTemplateExecutor) WithDefaultPolicy(policy string) (*v1alphaTemplateExecutor, error) {
+	if !slices.Contains(v1alpha.FilePolicies, policy) {
+		return nil, fmt.Errorf("invalid policy '%s', policy must be either %s",
+			policy, english.OxfordWordSeries(v1alpha.FilePolicies, "or"))
+	}
+
+	g.defaultPolicy = policy
+
+	return g, nil
+}
+
+// WithPrompt sets the prompt of the generated file of prompt policy.
+func (g *v1alphaTemplateExecutor) WithPrompt(prompt func(file string) (bool, error)) *v1alphaTemplateExecutor {
+	g.prompt = prompt
+
+	return g
+}
+
+// WithAssets sets the function that returns the fs of the directory of
+// the manifest file, which the copy files of the code template are copied
+// from.
+func (g *v1alphaTemplateExecutor) WithAssets(assets func(b core.Base) (fs.FS, error)) *v1alphaTemplateExecutor {
+	g.assets = assets
+
+	return g
+}
+
 var errCodeTemplateConsumtptionNotReady = errors.New("found condition CodeTemplateConsumptionReady is false")
 var errResourceNotReady = errors.New("found condition ResourceReady is false")
 
 func (g *v1alphaTemplateExecutor) Generate(r *v1alpha.FormResultManifest, t *v1alpha.CodeTemplateManifest) error {
+	// the secrets are redacted from the targets, as they are logged.
 	log := g.log.WithFields(logrus.Fields{
 		"targets": []interface{}{
-			r, t,
+			r.Redacted(), t.Redacted(r.Spec.Secrets()),
 		},
 	})
 
@@ -50,27 +98,12 @@ func (g *v1alphaTemplateExecutor) Generate(r *v1alpha.FormResultManifest, t *v1a
 	}
 
 	t.Status.GeneratedCodeFiles = []v1alpha.CodeTemplateStatusResult{}
+	t.Status.PatchedFiles = nil
 
 	// TODO: do CEL preflight check!
-	tmpl := template.New("alchemy-main")
-
-	templateKind := t.Spec.Kind
-	switch templateKind {
-	case "go-template":
-		for _, opt := range t.Spec.Options {
-			switch opt {
-			case "funcs=sprig":
-				tmpl = tmpl.Funcs(sprig.FuncMap())
-			case "missingkey=error":
-				tmpl = tmpl.Option("missingkey=error")
-			default:
-				log.Panicf("invalid opt found %s, looks like it is a bug and it must be vetted early", opt)
-			}
-
-			log.Tracef("template option '%s' added", opt)
-		}
-	default:
-		log.Panicf("invalid template kind found %s, looks like it is a bug and it must be vetted early", templateKind)
+	engine, err := newTemplateEngine(t.Spec.Kind, t.Spec.Options)
+	if err != nil {
+		return core.NewPathError("spec.kind", err)
 	}
 
 	// TODO: normalise value before hand, based on type hints
@@ -94,24 +127,68 @@ func (g *v1alphaTemplateExecutor) Generate(r *v1alpha.FormResultManifest, t *v1a
 		r.Spec.Result[name] = val
 	}
 
-	// TODO: support multiple templating engine
-	for _, g := range t.Spec.GenerateFiles {
-		output, err := tmpl.Parse(g.Template)
+	files := map[string]bool{}
+	for i, f := range t.Spec.GenerateFiles {
+		fieldPath := fmt.Sprintf("spec.generateFiles[%v]", i)
+
+		iterations, err := forEach(f, r.Spec.Result)
 		if err != nil {
-			return err
+			return core.NewPathError(fieldPath+".forEach", err)
 		}
 
-		var code bytes.Buffer
+		for _, vars := range iterations {
+			file, err := renderFilePath(engine, i, f.File, r.Spec.Result, vars)
+			if err != nil {
+				return core.NewPathError(fieldPath+".file", err)
+			}
+			if files[file] {
+				return core.NewPathError(fieldPath+".file", fmt.Errorf("rendered file path '%s' is duplicated", file))
+			}
+			files[file] = true
 
-		err = output.Execute(&code, r.Spec.Result)
-		if err != nil {
-			return err
+			generated, err := when(f.When, r.Spec.Result, vars)
+			if err != nil {
+				return core.NewPathError(fieldPath+".when", err)
+			}
+
+			// file not generated is blank, so that it is treated the same
+			// as the blank code by its onEmpty.
+			var code string
+			if generated {
+				code, err = engine.Render(fmt.Sprintf("alchemy-%d", i), f.Template, r.Spec.Result, vars)
+				if err != nil {
+					return core.NewPathError(fieldPath+".template", err)
+				}
+			} else {
+				log.Debugf("file '%s' is not generated as its condition is false", file)
+			}
+
+			t.Status.GeneratedCodeFiles = append(t.Status.GeneratedCodeFiles, v1alpha.CodeTemplateStatusResult{
+				File:    file,
+				Code:    code,
+				Policy:  f.Policy,
+				OnEmpty: f.OnEmpty,
+				Mode:    f.Mode,
+			})
+		}
+	}
+
+	copied, err := g.copyFiles(t, engine, r.Spec.Result)
+	if err != nil {
+		return err
+	}
+	for _, f := range copied {
+		if files[f.File] {
+			return fmt.Errorf("copied file '%s' is duplicated", f.File)
 		}
+		files[f.File] = true
+
+		t.Status.GeneratedCodeFiles = append(t.Status.GeneratedCodeFiles, f)
+	}
 
-		t.Status.GeneratedCodeFiles = append(t.Status.GeneratedCodeFiles, v1alpha.CodeTemplateStatusResult{
-			File: g.File,
-			Code: code.String(),
-		})
+	t.Status.PatchedFiles, err = g.patchFiles(t, engine, r.Spec.Result, files)
+	if err != nil {
+		return err
 	}
 
 	t.Status.SetCondition(v1alpha.CodeTemplateConsumptionDone, true)
@@ -119,6 +196,85 @@ func (g *v1alphaTemplateExecutor) Generate(r *v1alpha.FormResultManifest, t *v1a
 	return nil
 }
 
+// forEach returns the variables of every iteration of the generated file,
+// which is the element of its forEach by the name of as. There is a single
+// iteration without variables if forEach is unset.
+func forEach(f v1alpha.GenerateFile, result map[string]any) ([]map[string]any, error) {
+	if f.ForEach == "" {
+		return []map[string]any{nil}, nil
+	}
+
+	out, err := system.ExecuteCELOnResult(result, nil, f.ForEach)
+	if err != nil {
+		return nil, err
+	}
+
+	list := reflect.ValueOf(out.Value())
+	if list.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("output type must be list, but found '%s'", out.Type().TypeName())
+	}
+
+	as := lo.CoalesceOrEmpty(f.As, v1alpha.DefaultForEachAs)
+
+	output := []map[string]any{}
+	for i := 0; i < list.Len(); i++ {
+		elem := list.Index(i).Interface()
+
+		// element of list literal is wrapped as CEL value
+		if v, ok := elem.(ref.Val); ok {
+			elem = v.Value()
+		}
+
+		output = append(output, map[string]any{as: elem})
+	}
+
+	return output, nil
+}
+
+// when evaluates the condition of the file along with the variables of the
+// iteration, it is true if the condition is unset.
+func when(condition string, result map[string]any, vars map[string]any) (bool, error) {
+	if condition == "" {
+		return true, nil
+	}
+
+	out, err := system.ExecuteCELOnResult(result, vars, condition)
+	if err != nil {
+		return false, err
+	}
+
+	outcome, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("output type must be boolean, but found '%s'", out.Type().TypeName())
+	}
+
+	return outcome, nil
+}
+
+// renderFilePath renders the templated file path, which is then validated
+// as the rendered path could escape the output directory.
+func renderFilePath(engine templateEngine, index int, file string, result map[string]any, vars map[string]any) (string, error) {
+	name := fmt.Sprintf("alchemy-file-%d", index)
+
+	var err error
+	if i, ok := engine.(interpolator); ok {
+		file, err = i.Interpolate(name, file, result, vars)
+	} else {
+		file, err = engine.Render(name, file, result, vars)
+	}
+	if err != nil {
+		return "", err
+	}
+	file = strings.TrimSpace(file)
+
+	err = v1alpha.ValidateFilePath(file)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Clean(filepath.ToSlash(file)), nil
+}
+
 var errCodeTemplateConsumptionNotDone = errors.New("found condition CodeTemplateConsumptionDone is false")
 
 func (g *v1alphaTemplateExecutor) MakeFiles(dir string, s *v1alpha.CodeTemplateStatus) error {
@@ -136,41 +292,264 @@ func (g *v1alphaTemplateExecutor) MakeFiles(dir string, s *v1alpha.CodeTemplateS
 	}
 
 	fs := afero.NewOsFs()
-	for _, f := range s.GeneratedCodeFiles {
-		fileDirectory := path.Join(absPath, filepath.Dir(f.File))
 
-		err := fs.MkdirAll(fileDirectory, os.ModePerm)
+	// actions are resolved before making any file, so that nothing is
+	// made should any file fail its policy.
+	var errs error
+	for i, f := range s.GeneratedCodeFiles {
+		target, err := sandboxPath(absPath, f.File)
 		if err != nil {
-			s.SetError(fmt.Errorf("unable to make directory '%s': %w", fileDirectory, err))
+			s.SetError(err)
+			errs = errors.Join(errs, err)
 
-			return err
+			continue
 		}
 
-		log.WithField("fileDirectory", fileDirectory).Tracef("make directory '%s' done", fileDirectory)
-		absDir := filepath.Join(fileDirectory, filepath.Base(f.File))
+		exists, err := fileExists(fs, target)
+		if err != nil {
+			s.SetError(err)
+			errs = errors.Join(errs, err)
+
+			continue
+		}
 
-		// redo everything
-		_ = fs.Remove(absDir)
+		action, err := g.resolveAction(f, exists, true)
+		if err != nil {
+			s.SetError(err)
+			errs = errors.Join(errs, err)
 
-		// should the code block is empty - silently ignore
-		if strings.TrimSpace(f.Code) == "" {
 			continue
 		}
+		s.GeneratedCodeFiles[i].Action = action
+	}
+	if errs != nil {
+		return errs
+	}
 
-		file, err := fs.Create(absDir)
+	lock, err := readLock(fs, absPath)
+	if err != nil {
+		s.SetError(err)
+
+		return err
+	}
+
+	patched, err := g.applyPatches(fs, absPath, lock, s)
+	if err != nil {
+		s.SetError(err)
+
+		return err
+	}
+
+	// files are staged then committed all or nothing, so that the
+	// directory is never left half-generated.
+	tx, err := newFileTransaction(fs, absPath, log)
+	if err != nil {
+		s.SetError(err)
+
+		return err
+	}
+	defer tx.Close()
+
+	for _, f := range s.GeneratedCodeFiles {
+		switch f.Action {
+		case v1alpha.FileCreated, v1alpha.FileOverwritten, v1alpha.FileAppended:
+			err = stageFile(tx, fs, filepath.Join(absPath, f.File), f)
+		case v1alpha.FileDeleted:
+			tx.Delete(f.File)
+		}
 		if err != nil {
+			s.SetError(err)
+			s.SetCondition(v1alpha.CodeTemplateFilesCommitted, false)
+
 			return err
 		}
+	}
 
-		defer file.Close()
+	for _, f := range patched {
+		if f.content == f.current {
+			continue
+		}
 
-		_, err = file.WriteString(f.Code)
+		err = tx.Write(f.path, []byte(f.content), f.mode)
 		if err != nil {
+			s.SetError(err)
+			s.SetCondition(v1alpha.CodeTemplateFilesCommitted, false)
+
 			return err
 		}
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		s.SetError(err)
+		s.SetCondition(v1alpha.CodeTemplateFilesCommitted, false)
+
+		return err
+	}
+	s.SetCondition(v1alpha.CodeTemplateFilesCommitted, true)
+
+	log.Tracef("files are committed into '%s'", absPath)
+
 	utils.Tell("✨ Code Generated", fmt.Sprintf("Alchemy has created code into '%s' from forms without any issues.", dir))
 
 	return nil
 }
+
+// stageFile stages the generated file into the transaction, where the
+// appended file is staged along with its existing content.
+func stageFile(tx *fileTransaction, fs afero.Fs, target string, f v1alpha.CodeTemplateStatusResult) error {
+	mode, err := fileMode(fs, target, f.Mode)
+	if err != nil {
+		return err
+	}
+
+	content, err := f.Content()
+	if err != nil {
+		return err
+	}
+	if f.Action == v1alpha.FileAppended {
+		current, err := afero.ReadFile(fs, target)
+		if err != nil {
+			return err
+		}
+		content = append(current, content...)
+	}
+
+	return tx.Write(f.File, content, mode)
+}
+
+// resolveAction resolves the action of the generated file by its policy
+// and whether the file already exists. The prompt policy is resolved as
+// overwrite if it is not interactive, such as during dry run, whereas it
+// fails to be resolved without the prompt, such as the run of values.
+func (g *v1alphaTemplateExecutor) resolveAction(f v1alpha.CodeTemplateStatusResult, exists bool, interactive bool) (string, error) {
+	if !f.IsWritten() {
+		switch {
+		case !exists:
+			return v1alpha.FileSkipped, nil
+		case f.OnEmpty == v1alpha.OnEmptyKeep:
+			return v1alpha.FileKept, nil
+		default:
+			return v1alpha.FileDeleted, nil
+		}
+	}
+
+	if !exists {
+		return v1alpha.FileCreated, nil
+	}
+
+	policy := lo.CoalesceOrEmpty(f.Policy, g.defaultPolicy)
+	switch policy {
+	case v1alpha.OverwritePolicy:
+		return v1alpha.FileOverwritten, nil
+	case v1alpha.SkipIfExistsPolicy:
+		return v1alpha.FileSkipped, nil
+	case v1alpha.FailIfExistsPolicy:
+		return "", fmt.Errorf("file '%s' already exists, which is not allowed by policy '%s'", f.File, policy)
+	case v1alpha.AppendPolicy:
+		return v1alpha.FileAppended, nil
+	case v1alpha.PromptPolicy:
+		if !interactive {
+			return v1alpha.FileOverwritten, nil
+		}
+		if g.prompt == nil {
+			return "", fmt.Errorf("file '%s' already exists, which is to be prompted by policy '%s' but the run is not interactive, "+
+				"set a policy of no prompt such as --policy %s instead", f.File, policy, v1alpha.OverwritePolicy)
+		}
+
+		overwrite, err := g.prompt(f.File)
+		if err != nil {
+			return "", err
+		}
+		if overwrite {
+			return v1alpha.FileOverwritten, nil
+		}
+
+		return v1alpha.FileSkipped, nil
+	default:
+		return "", fmt.Errorf("invalid policy '%s' of file '%s'", policy, f.File)
+	}
+}
+
+// defaultFileMode is the mode of the new file that has no mode of its own.
+const defaultFileMode os.FileMode = 0644
+
+// fileMode returns the mode of the file to be written, which is the mode of
+// the generated file if set, else the mode of the existing file, else the
+// default mode.
+func fileMode(fs afero.Fs, target string, mode string) (os.FileMode, error) {
+	if mode != "" {
+		return v1alpha.ParseFileMode(mode)
+	}
+
+	current, exists, err := currentFileMode(fs, target)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return defaultFileMode, nil
+	}
+
+	return current, nil
+}
+
+// fileExists reports whether the file exists, it returns error if the
+// target is a directory, as the directory is never replaced nor deleted
+// by the generated file.
+func fileExists(fs afero.Fs, target string) (bool, error) {
+	info, err := fs.Stat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		return false, fmt.Errorf("'%s' is a directory rather than a file", target)
+	}
+
+	return true, nil
+}
+
+// currentFileMode returns the permission bits of the existing file, it
+// reports whether the file exists.
+func currentFileMode(fs afero.Fs, target string) (mode os.FileMode, exists bool, err error) {
+	info, err := fs.Stat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return info.Mode().Perm(), true, nil
+}
+
+// WriteLock writes the lock file into the directory of the generated code,
+// replacing the lock file of the previous generation.
+func (g *v1alphaTemplateExecutor) WriteLock(dir string, lock *v1alpha.LockManifest) error {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b,
+		yaml.UseLiteralStyleIfMultiline(true),
+
+		// for readability
+		yaml.IndentSequence(true),
+		yaml.Indent(2),
+	)
+
+	err := encoder.Encode(lock)
+	if err != nil {
+		return err
+	}
+
+	lockPath := filepath.Join(dir, v1alpha.LockFileName)
+
+	err = afero.WriteFile(afero.NewOsFs(), lockPath, b.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write lock file '%s': %w", lockPath, err)
+	}
+
+	g.log.WithField("lockPath", lockPath).Debugf("lock file '%s' written", lockPath)
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeFilesPolicies(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, file := range []string{"overwrite.txt", "skip.txt", "append.txt", "delete.txt", "keep.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte("old\n"), 0644))
+	}
+
+	s := &v1alpha.CodeTemplateStatus{
+		GeneratedCodeFiles: []v1alpha.CodeTemplateStatusResult{
+			{File: "dir/created.txt", Code: "new\n", Policy: v1alpha.FailIfExistsPolicy},
+			{File: "overwrite.txt", Code: "new\n"},
+			{File: "skip.txt", Code: "new\n", Policy: v1alpha.SkipIfExistsPolicy},
+			{File: "append.txt", Code: "new\n", Policy: v1alpha.AppendPolicy},
+			{File: "delete.txt", Code: ""},
+			{File: "keep.txt", Code: "", OnEmpty: v1alpha.OnEmptyKeep},
+		},
+	}
+	s.SetCondition(v1alpha.CodeTemplateConsumptionDone, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	require.NoError(t, g.MakeFiles(dir, s))
+
+	expected := map[string]struct {
+		action  string
+		content string
+	}{
+		"dir/created.txt": {v1alpha.FileCreated, "new\n"},
+		"overwrite.txt":   {v1alpha.FileOverwritten, "new\n"},
+		"skip.txt":        {v1alpha.FileSkipped, "old\n"},
+		"append.txt":      {v1alpha.FileAppended, "old\nnew\n"},
+		"delete.txt":      {v1alpha.FileDeleted, ""},
+		"keep.txt":        {v1alpha.FileKept, "old\n"},
+	}
+	for _, f := range s.GeneratedCodeFiles {
+		assert.Equal(t, expected[f.File].action, f.Action, "action of '%s' must be reported", f.File)
+
+		b, err := os.ReadFile(filepath.Join(dir, f.File))
+		if expected[f.File].action == v1alpha.FileDeleted {
+			assert.ErrorIs(t, err, os.ErrNotExist, "'%s' must be deleted", f.File)
+
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, expected[f.File].content, string(b), "content of '%s' must be equal", f.File)
+	}
+}
+
+func TestMakeFilesFailIfExists(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "exists.txt"), []byte("old\n"), 0644))
+
+	s := &v1alpha.CodeTemplateStatus{
+		GeneratedCodeFiles: []v1alpha.CodeTemplateStatusResult{
+			{File: "created.txt", Code: "new\n"},
+			{File: "exists.txt", Code: "new\n"},
+		},
+	}
+	s.SetCondition(v1alpha.CodeTemplateConsumptionDone, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+	g, err = g.WithDefaultPolicy(v1alpha.FailIfExistsPolicy)
+	require.NoError(t, err)
+
+	err = g.MakeFiles(dir, s)
+	require.Error(t, err, "existing file must fail")
+	assert.True(t, s.HasErr(), "error must be reported in status")
+
+	_, err = os.Stat(filepath.Join(dir, "created.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "nothing must be made should any file fail")
+}
+
+func TestMakeFilesPromptWithoutTerminal(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "exists.txt"), []byte("old\n"), 0644))
+
+	newStatus := func() *v1alpha.CodeTemplateStatus {
+		s := &v1alpha.CodeTemplateStatus{
+			GeneratedCodeFiles: []v1alpha.CodeTemplateStatusResult{
+				{File: "exists.txt", Code: "new\n", Policy: v1alpha.PromptPolicy},
+			},
+		}
+		s.SetCondition(v1alpha.CodeTemplateConsumptionDone, true)
+
+		return s
+	}
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	// the run of values has no prompt
+	err = g.MakeFiles(dir, newStatus())
+	assert.ErrorContains(t, err, "the run is not interactive")
+
+	b, err := os.ReadFile(filepath.Join(dir, "exists.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "old\n", string(b))
+
+	prompted := []string{}
+	g = g.WithPrompt(func(file string) (bool, error) {
+		prompted = append(prompted, file)
+
+		return false, nil
+	})
+	s := newStatus()
+	require.NoError(t, g.MakeFiles(dir, s))
+	assert.Equal(t, []string{"exists.txt"}, prompted)
+	assert.Equal(t, v1alpha.FileSkipped, s.GeneratedCodeFiles[0].Action)
+}
+
+func TestMakeFilesMode(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "retained.sh"), []byte("old\n"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "changed.sh"), []byte("old\n"), 0644))
+
+	s := &v1alpha.CodeTemplateStatus{
+		GeneratedCodeFiles: []v1alpha.CodeTemplateStatusResult{
+			{File: "scripts/deploy.sh", Code: "new\n", Mode: "0755"},
+			{File: "default.txt", Code: "new\n"},
+			{File: "retained.sh", Code: "new\n"},
+			{File: "changed.sh", Code: "new\n", Mode: "0750"},
+		},
+	}
+	s.SetCondition(v1alpha.CodeTemplateConsumptionDone, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	files, err := g.Diff(dir, s)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), files[3].Mode)
+	assert.Equal(t, os.FileMode(0644), files[3].CurrentMode)
+	assert.Equal(t, DiffModified, files[3].Action)
+	assert.Contains(t, files[3].UnifiedDiff, "old mode 0644\nnew mode 0750\n")
+
+	require.NoError(t, g.MakeFiles(dir, s))
+
+	for file, mode := range map[string]os.FileMode{
+		"scripts/deploy.sh": 0755,
+		"default.txt":       0644,
+		"retained.sh":       0700,
+		"changed.sh":        0750,
+	} {
+		info, err := os.Stat(filepath.Join(dir, file))
+		require.NoError(t, err)
+		assert.Equal(t, mode, info.Mode().Perm(), "mode of '%s' must be equal", file)
+	}
+}
+
+func TestGenerateWhenAndForEach(t *testing.T) {
+	r := &v1alpha.FormResultManifest{
+		Spec: v1alpha.FormResultSpec{
+			Result: map[string]any{
+				"name":        "app",
+				"protect_app": false,
+				"regions":     []string{"us-east-1", "eu-west-1", "ap-south-1"},
+			},
+		},
+	}
+	r.Status.SetCondition(v1alpha.CodeTemplateConsumptionReady, true)
+
+	ct := &v1alpha.CodeTemplateManifest{
+		Spec: v1alpha.CodeTemplateSpec{
+			Kind: "go-template",
+			GenerateFiles: []v1alpha.GenerateFile{
+				{File: "pdb.yaml", Template: "name: {{ .name }}", When: "result.protect_app"},
+				{
+					File:     "regions/{{ .region }}.tfvars",
+					Template: `region = "{{ .region }}"`,
+					ForEach:  "result.regions",
+					As:       "region",
+					When:     `!region.startsWith("ap-")`,
+				},
+				{File: "{{ .item }}.txt", Template: "{{ .item }}", ForEach: `["a", "b"]`},
+			},
+		},
+	}
+	ct.Status.SetCondition(core.ResourceReady, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+	require.NoError(t, g.Generate(r, ct))
+
+	codes := map[string]string{}
+	for _, f := range ct.Status.GeneratedCodeFiles {
+		codes[f.File] = f.Code
+	}
+	assert.Equal(t, map[string]string{
+		"pdb.yaml":                  "",
+		"regions/us-east-1.tfvars":  `region = "us-east-1"`,
+		"regions/eu-west-1.tfvars":  `region = "eu-west-1"`,
+		"regions/ap-south-1.tfvars": "",
+		"a.txt":                     "a",
+		"b.txt":                     "b",
+	}, codes)
+
+	ct.Spec.GenerateFiles = []v1alpha.GenerateFile{
+		{File: "static.txt", Template: "{{ .item }}", ForEach: "result.regions"},
+	}
+	err = g.Generate(r, ct)
+	assert.ErrorContains(t, err, "is duplicated", "file path must be unique per element")
+
+	ct.Spec.GenerateFiles = []v1alpha.GenerateFile{
+		{File: "{{ .item }}.txt", Template: "{{ .item }}", ForEach: "result.name"},
+	}
+	err = g.Generate(r, ct)
+	assert.ErrorContains(t, err, "spec.generateFiles[0].forEach")
+}
@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	conflictMarkerCurrent  string = "<<<<<<< current"
+	conflictMarkerSplit    string = "======="
+	conflictMarkerUpgraded string = ">>>>>>> upgraded"
+)
+
+// Merge3 merges the changes of both current and upgraded content on top of
+// the base content line by line, where the base content is the originally
+// generated content.
+//
+// Lines changed on one side only are taken as is, whereas lines changed on
+// both sides differently are conflicts, which are surrounded by the
+// conflict markers. It returns the merged content and the total conflicts.
+func Merge3(base, current, upgraded string) (merged string, conflicts int) {
+	o, a, b := splitLines(base), splitLines(current), splitLines(upgraded)
+
+	matchesA := matchLines(o, a)
+	matchesB := matchLines(o, b)
+
+	var out strings.Builder
+
+	i, ja, jb := 0, 0, 0
+	for {
+		// find the next line of base that is retained on both sides,
+		// everything in between is a chunk changed on either side.
+		k := i
+		for k < len(o) && (matchesA[k] < ja || matchesB[k] < jb) {
+			k++
+		}
+
+		endA, endB := len(a), len(b)
+		if k < len(o) {
+			endA, endB = matchesA[k], matchesB[k]
+		}
+
+		chunkO, chunkA, chunkB := o[i:k], a[ja:endA], b[jb:endB]
+		switch {
+		case slices.Equal(chunkA, chunkB):
+			out.WriteString(strings.Join(chunkA, ""))
+		case slices.Equal(chunkO, chunkA):
+			out.WriteString(strings.Join(chunkB, ""))
+		case slices.Equal(chunkO, chunkB):
+			out.WriteString(strings.Join(chunkA, ""))
+		default:
+			conflicts++
+
+			writeConflictLines(&out, conflictMarkerCurrent)
+			writeConflictLines(&out, chunkA...)
+			writeConflictLines(&out, conflictMarkerSplit)
+			writeConflictLines(&out, chunkB...)
+			writeConflictLines(&out, conflictMarkerUpgraded)
+		}
+
+		if k == len(o) {
+			break
+		}
+
+		out.WriteString(o[k])
+		i, ja, jb = k+1, endA+1, endB+1
+	}
+
+	return out.String(), conflicts
+}
+
+// matchLines returns the index of the matching line in b for every line of
+// a, it is -1 if the line is not retained in b.
+func matchLines(a, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	m := difflib.NewMatcherWithJunk(a, b, false, nil)
+	for _, block := range m.GetMatchingBlocks() {
+		for n := 0; n < block.Size; n++ {
+			matches[block.A+n] = block.B + n
+		}
+	}
+
+	return matches
+}
+
+// splitLines splits the content into lines, where every line retains its
+// line break.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// writeConflictLines writes the lines of conflict, the line break is added
+// to the line that has none, so that the conflict marker always starts
+// from a new line.
+func writeConflictLines(out *strings.Builder, lines ...string) {
+	for _, line := range lines {
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+	}
+}
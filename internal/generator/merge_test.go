@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\n"
+
+	tests := []struct {
+		name      string
+		base      *string
+		current   string
+		upgraded  string
+		merged    string
+		conflicts int
+	}{
+		{
+			name:     "unchanged",
+			current:  base,
+			upgraded: base,
+			merged:   base,
+		},
+		{
+			name:     "changed upstream only",
+			current:  base,
+			upgraded: "a\nB\nc\nd\ne\n",
+			merged:   "a\nB\nc\nd\ne\n",
+		},
+		{
+			name:     "changed locally only",
+			current:  "a\nb\nlocal\nc\nd\n",
+			upgraded: base,
+			merged:   "a\nb\nlocal\nc\nd\n",
+		},
+		{
+			name:     "changed on both sides without overlapping",
+			current:  "local\na\nb\nc\nd\n",
+			upgraded: "a\nb\nc\nD\n",
+			merged:   "local\na\nb\nc\nD\n",
+		},
+		{
+			name:     "changed on both sides identically",
+			current:  "a\nB\nc\nd\n",
+			upgraded: "a\nB\nc\nd\n",
+			merged:   "a\nB\nc\nd\n",
+		},
+		{
+			name:      "conflict",
+			current:   "a\nlocal\nc\nd\n",
+			upgraded:  "a\nupgraded\nc\nd",
+			merged:    "a\n<<<<<<< current\nlocal\n=======\nupgraded\n>>>>>>> upgraded\nc\nd",
+			conflicts: 1,
+		},
+		{
+			name:      "new file exists locally",
+			base:      new(string),
+			current:   "local\n",
+			upgraded:  "upgraded\n",
+			merged:    "<<<<<<< current\nlocal\n=======\nupgraded\n>>>>>>> upgraded\n",
+			conflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := base
+			if tt.base != nil {
+				b = *tt.base
+			}
+
+			merged, conflicts := Merge3(b, tt.current, tt.upgraded)
+
+			assert.Equal(t, tt.merged, merged)
+			assert.Equal(t, tt.conflicts, conflicts)
+		})
+	}
+}
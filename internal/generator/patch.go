@@ -0,0 +1,408 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/goccy/go-yaml"
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/spf13/afero"
+)
+
+// patchFiles renders the patches of the code template, where the file
+// generated by the code template cannot be patched.
+func (g *v1alphaTemplateExecutor) patchFiles(
+	t *v1alpha.CodeTemplateManifest, engine templateEngine, result map[string]any, generated map[string]bool,
+) ([]v1alpha.CodeTemplatePatchResult, error) {
+	output := []v1alpha.CodeTemplatePatchResult{}
+	for i, p := range t.Spec.PatchFiles {
+		fieldPath := fmt.Sprintf("spec.patchFiles[%v]", i)
+
+		patched, err := when(p.When, result, nil)
+		if err != nil {
+			return nil, core.NewPathError(fieldPath+".when", err)
+		}
+		if !patched {
+			g.log.Debugf("file '%s' is not patched as its condition is false", p.File)
+			continue
+		}
+
+		file, err := renderFilePath(engine, i, p.File, result, nil)
+		if err != nil {
+			return nil, core.NewPathError(fieldPath+".file", err)
+		}
+		if generated[file] {
+			return nil, core.NewPathError(fieldPath+".file", fmt.Errorf("file '%s' is generated by the code template, thus it cannot be patched", file))
+		}
+
+		patch, err := engine.Render(fmt.Sprintf("alchemy-patch-%d", i), p.Template, result, nil)
+		if err != nil {
+			return nil, core.NewPathError(fieldPath+".template", err)
+		}
+
+		output = append(output, v1alpha.CodeTemplatePatchResult{
+			File:     file,
+			Type:     p.Type,
+			Patch:    patch,
+			After:    p.After,
+			Before:   p.Before,
+			Optional: p.Optional,
+		})
+	}
+
+	return output, nil
+}
+
+// patchedFile is the outcome of every patch of the same file.
+type patchedFile struct {
+	path string
+
+	// current is the content of the existing file, whereas content is
+	// the content after every patch is applied.
+	current string
+	content string
+	mode    os.FileMode
+}
+
+// applyPatches applies the patches of the status on the existing files of
+// the directory in order, and sets the action of every patch. The patches
+// of the same file are applied on top of each other, and the optional
+// patch of missing file is skipped. The patches recorded in the lock of the
+// previous generation are applied already, hence they are skipped too.
+func (g *v1alphaTemplateExecutor) applyPatches(fs afero.Fs, dir string, lock *v1alpha.LockManifest, s *v1alpha.CodeTemplateStatus) ([]*patchedFile, error) {
+	output := []*patchedFile{}
+	files := map[string]*patchedFile{}
+
+	for i, p := range s.PatchedFiles {
+		if lock != nil && lock.Spec.Patched(p) {
+			s.PatchedFiles[i].Action = v1alpha.FileApplied
+			g.log.Debugf("patch of file '%s' is applied already", p.File)
+
+			continue
+		}
+
+		f, ok := files[p.File]
+		if !ok {
+			target, err := sandboxPath(dir, p.File)
+			if err != nil {
+				return nil, err
+			}
+
+			current, exists, err := readFile(fs, target)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				if p.Optional {
+					s.PatchedFiles[i].Action = v1alpha.FileSkipped
+					g.log.Debugf("optional patch of missing file '%s' is skipped", p.File)
+
+					continue
+				}
+
+				return nil, core.NewPathError(p.File, errors.New("file to be patched does not exist"))
+			}
+
+			mode, _, err := currentFileMode(fs, target)
+			if err != nil {
+				return nil, err
+			}
+
+			f = &patchedFile{path: p.File, current: current, content: current, mode: mode}
+			files[p.File] = f
+			output = append(output, f)
+		}
+
+		content, err := applyPatch(p, f.content)
+		if err != nil {
+			return nil, core.NewPathError(p.File, err)
+		}
+
+		s.PatchedFiles[i].Action = v1alpha.FilePatched
+		if content == f.content {
+			s.PatchedFiles[i].Action = v1alpha.FileUnchanged
+		}
+		f.content = content
+
+		g.log.WithField("file", p.File).Debugf("file '%s' is %s by %s", p.File, s.PatchedFiles[i].Action, p.Type)
+	}
+
+	return output, nil
+}
+
+// applyPatch applies the patch on the content of the file by its type.
+func applyPatch(p v1alpha.CodeTemplatePatchResult, content string) (string, error) {
+	switch p.Type {
+	case v1alpha.InsertPatchType:
+		return insertText(content, p.Patch, p.After, p.Before)
+	case v1alpha.JSONPatchType, v1alpha.YAMLMergeType:
+		doc, err := decodeDocument(content)
+		if err != nil {
+			return "", fmt.Errorf("unable to parse file: %w", err)
+		}
+
+		patch, err := decodeDocument(p.Patch)
+		if err != nil {
+			return "", fmt.Errorf("unable to parse patch: %w", err)
+		}
+
+		// the document is never modified in place by the patch
+		original := doc
+		if p.Type == v1alpha.JSONPatchType {
+			doc, err = applyJSONPatch(doc, patch)
+		} else {
+			doc = mergeYAML(doc, patch)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		// the file is encoded again only if it is changed, so that its
+		// formatting and comments are retained otherwise
+		if reflect.DeepEqual(original, doc) {
+			return content, nil
+		}
+
+		return encodeDocument(p.File, doc)
+	default:
+		return "", fmt.Errorf("invalid patch type '%s'", p.Type)
+	}
+}
+
+// insertText inserts the text after (or before) the line of the first
+// match of the anchor, else it appends the text to the end of the content.
+// The text is inserted once only, thus it is not inserted again if the
+// content contains it already.
+func insertText(content string, text string, after string, before string) (string, error) {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	if strings.Contains(content, text) {
+		return content, nil
+	}
+
+	anchor := after + before
+	if anchor == "" {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+
+		return content + text, nil
+	}
+
+	re, err := regexp.Compile("(?m)" + anchor)
+	if err != nil {
+		return "", fmt.Errorf("invalid anchor '%s': %w", anchor, err)
+	}
+
+	loc := re.FindStringIndex(content)
+	if loc == nil {
+		return "", fmt.Errorf("anchor '%s' not found", anchor)
+	}
+
+	var at int
+	if after != "" {
+		// end of the line of the end of the match
+		at = len(content)
+		if i := strings.Index(content[loc[1]:], "\n"); i >= 0 {
+			at = loc[1] + i + 1
+		} else {
+			content += "\n"
+			at = len(content)
+		}
+	} else {
+		// start of the line of the start of the match
+		at = strings.LastIndex(content[:loc[0]], "\n") + 1
+	}
+
+	return content[:at] + text + content[at:], nil
+}
+
+// decodeDocument decodes the single YAML (or JSON) document with the order
+// of its keys retained.
+func decodeDocument(content string) (any, error) {
+	var doc any
+	err := yaml.UnmarshalWithOptions([]byte(content), &doc, yaml.UseOrderedMap())
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// encodeDocument encodes the document as indented JSON for JSON file, else
+// as YAML.
+func encodeDocument(file string, doc any) (string, error) {
+	if path.Ext(file) != ".json" {
+		b, err := yaml.MarshalWithOptions(doc, yaml.IndentSequence(true), yaml.Indent(2))
+		if err != nil {
+			return "", err
+		}
+
+		return string(b), nil
+	}
+
+	b, err := yaml.MarshalWithOptions(doc, yaml.JSON())
+	if err != nil {
+		return "", err
+	}
+
+	var indented bytes.Buffer
+	err = json.Indent(&indented, bytes.TrimSpace(b), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	indented.WriteString("\n")
+
+	return indented.String(), nil
+}
+
+// mergeYAML merges the patch into the document strategically: maps are
+// merged recursively where null removes the key, list elements of map are
+// merged into the element of the same `name`, other list elements are
+// appended unless they exist already, and anything else is replaced.
+func mergeYAML(doc any, patch any) any {
+	switch p := patch.(type) {
+	case yaml.MapSlice:
+		d, ok := doc.(yaml.MapSlice)
+		if !ok {
+			return removeNulls(p)
+		}
+
+		output := slices.Clone(d)
+		for _, item := range p {
+			i := slices.IndexFunc(output, func(o yaml.MapItem) bool {
+				return o.Key == item.Key
+			})
+
+			switch {
+			case item.Value == nil && i >= 0:
+				output = slices.Delete(output, i, i+1)
+			case item.Value == nil:
+			case i >= 0:
+				output[i].Value = mergeYAML(output[i].Value, item.Value)
+			default:
+				output = append(output, yaml.MapItem{Key: item.Key, Value: removeNulls(item.Value)})
+			}
+		}
+
+		return output
+	case []any:
+		d, ok := doc.([]any)
+		if !ok {
+			return p
+		}
+
+		output := slices.Clone(d)
+		for _, elem := range p {
+			if name, ok := elementName(elem); ok {
+				i := slices.IndexFunc(output, func(o any) bool {
+					n, ok := elementName(o)
+					return ok && n == name
+				})
+				if i >= 0 {
+					output[i] = mergeYAML(output[i], elem)
+					continue
+				}
+			}
+
+			exists := slices.ContainsFunc(output, func(o any) bool {
+				return reflect.DeepEqual(o, elem)
+			})
+			if !exists {
+				output = append(output, elem)
+			}
+		}
+
+		return output
+	default:
+		return p
+	}
+}
+
+// removeNulls removes the keys of null from the map added by the merge.
+func removeNulls(v any) any {
+	m, ok := v.(yaml.MapSlice)
+	if !ok {
+		return v
+	}
+
+	output := yaml.MapSlice{}
+	for _, item := range m {
+		if item.Value != nil {
+			output = append(output, yaml.MapItem{Key: item.Key, Value: removeNulls(item.Value)})
+		}
+	}
+
+	return output
+}
+
+// elementName returns the `name` of the list element of map.
+func elementName(v any) (any, bool) {
+	m, ok := v.(yaml.MapSlice)
+	if !ok {
+		return nil, false
+	}
+
+	for _, item := range m {
+		if item.Key == "name" && item.Value != nil {
+			return item.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// applyJSONPatch applies the JSON Patch (RFC 6902) on the document by
+// evanphx/json-patch, where the document is left untouched should any
+// operation fail, or should the patch change nothing.
+func applyJSONPatch(doc any, patch any) (any, error) {
+	if _, ok := patch.([]any); !ok {
+		return nil, errors.New("patch must be a list of JSON Patch operations")
+	}
+
+	in, err := yaml.MarshalWithOptions(doc, yaml.JSON())
+	if err != nil {
+		return nil, err
+	}
+	b, err := yaml.MarshalWithOptions(patch, yaml.JSON())
+	if err != nil {
+		return nil, err
+	}
+
+	operations, err := jsonpatch.DecodePatch(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON Patch: %w", err)
+	}
+
+	out, err := operations.Apply(in)
+	if err != nil {
+		return nil, err
+	}
+
+	// compared in their JSON form, as the types of YAML and JSON
+	// scalars differ
+	before, err := decodeDocument(string(in))
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeDocument(string(out))
+	if err != nil {
+		return nil, err
+	}
+	if reflect.DeepEqual(before, after) {
+		return doc, nil
+	}
+
+	return after, nil
+}
@@ -0,0 +1,377 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatch(t *testing.T) {
+	compose := "services:\n  web:\n    image: web:1\n    ports:\n      - \"80:80\"\n"
+
+	tests := []struct {
+		name     string
+		patch    v1alpha.CodeTemplatePatchResult
+		content  string
+		expected string
+		err      string
+	}{
+		{
+			name: "yaml merge adds the service",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "docker-compose.yaml", Type: v1alpha.YAMLMergeType,
+				Patch: "services:\n  db:\n    image: postgres:16\n",
+			},
+			content:  compose,
+			expected: "services:\n  web:\n    image: web:1\n    ports:\n      - 80:80\n  db:\n    image: postgres:16\n",
+		},
+		{
+			name: "yaml merge appends missing list elements only",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "docker-compose.yaml", Type: v1alpha.YAMLMergeType,
+				Patch: "services:\n  web:\n    image: web:2\n    ports: [\"80:80\", \"443:443\"]\n",
+			},
+			content:  compose,
+			expected: "services:\n  web:\n    image: web:2\n    ports:\n      - 80:80\n      - 443:443\n",
+		},
+		{
+			name: "yaml merge merges list elements by name and removes null",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.yaml", Type: v1alpha.YAMLMergeType,
+				Patch: "containers:\n  - name: app\n    image: app:2\n    debug: null\n",
+			},
+			content:  "containers:\n  - name: app\n    image: app:1\n    debug: true\n  - name: sidecar\n",
+			expected: "containers:\n  - name: app\n    image: app:2\n  - name: sidecar\n",
+		},
+		{
+			name: "yaml merge of the same document is unchanged",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "docker-compose.yaml", Type: v1alpha.YAMLMergeType,
+				Patch: "services:\n  web:\n    image: web:1\n",
+			},
+			content:  "# comment is retained\n" + compose,
+			expected: "# comment is retained\n" + compose,
+		},
+		{
+			name: "json patch on json file",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "package.json", Type: v1alpha.JSONPatchType,
+				Patch: `[
+  {"op": "add", "path": "/scripts/lint", "value": "eslint ."},
+  {"op": "add", "path": "/files/-", "value": "dist"},
+  {"op": "add", "path": "/files/0", "value": "src"},
+  {"op": "remove", "path": "/private"},
+  {"op": "test", "path": "/name", "value": "app"},
+  {"op": "copy", "from": "/name", "path": "/a~1b"}
+]`,
+			},
+			content:  "{\"name\": \"app\", \"private\": true, \"scripts\": {\"test\": \"jest\"}, \"files\": [\"lib\"]}\n",
+			expected: "{\n  \"name\": \"app\",\n  \"scripts\": {\n    \"test\": \"jest\",\n    \"lint\": \"eslint .\"\n  },\n  \"files\": [\n    \"src\",\n    \"lib\",\n    \"dist\"\n  ],\n  \"a/b\": \"app\"\n}\n",
+		},
+		{
+			name: "json patch on yaml file",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.yaml", Type: v1alpha.JSONPatchType,
+				Patch: "- op: replace\n  path: /replicas\n  value: 3\n- op: move\n  from: /old\n  path: /new\n",
+			},
+			content:  "replicas: 1\nold: value\n",
+			expected: "replicas: 3\nnew: value\n",
+		},
+		{
+			name: "json patch fails test",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.yaml", Type: v1alpha.JSONPatchType,
+				Patch: "- op: test\n  path: /replicas\n  value: 2\n",
+			},
+			content: "replicas: 1\n",
+			err:     "test failed",
+		},
+		{
+			name: "json patch fails on missing key",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.yaml", Type: v1alpha.JSONPatchType,
+				Patch: "- op: replace\n  path: /image/tag\n  value: v2\n",
+			},
+			content: "replicas: 1\n",
+			err:     "missing value",
+		},
+		{
+			// RFC 6902 A.12, adding to a nonexistent target
+			name: "json patch fails on add to missing parent",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.yaml", Type: v1alpha.JSONPatchType,
+				Patch: "- op: add\n  path: /baz/bat\n  value: qux\n",
+			},
+			content: "foo: bar\n",
+			err:     "missing",
+		},
+		{
+			// RFC 6902 A.13, the invalid operation
+			name: "json patch fails on invalid op",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.yaml", Type: v1alpha.JSONPatchType,
+				Patch: "- op: merge\n  path: /foo\n  value: bar\n",
+			},
+			content: "foo: bar\n",
+			err:     "merge",
+		},
+		{
+			// RFC 6902 A.14, the escaped pointer
+			name: "json patch tests escaped pointer",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.json", Type: v1alpha.JSONPatchType,
+				Patch: `[{"op": "test", "path": "/~01", "value": 10}, {"op": "add", "path": "/done", "value": true}]`,
+			},
+			content:  `{"/": 9, "~1": 10}`,
+			expected: "{\n  \"/\": 9,\n  \"~1\": 10,\n  \"done\": true\n}\n",
+		},
+		{
+			// RFC 6902 A.16, the array value is added as is
+			name: "json patch adds array value",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.yaml", Type: v1alpha.JSONPatchType,
+				Patch: "- op: add\n  path: /foo/-\n  value: [abc, def]\n",
+			},
+			content:  "foo: [bar]\n",
+			expected: "foo:\n  - bar\n  - - abc\n    - def\n",
+		},
+		{
+			name: "json patch fails on index out of range",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.yaml", Type: v1alpha.JSONPatchType,
+				Patch: "- op: add\n  path: /foo/5\n  value: baz\n",
+			},
+			content: "foo: [bar]\n",
+			err:     "invalid index",
+		},
+		{
+			// the document is left untouched should any operation fail
+			name: "json patch fails as a whole",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "values.yaml", Type: v1alpha.JSONPatchType,
+				Patch: "- op: add\n  path: /new\n  value: 1\n- op: remove\n  path: /missing\n",
+			},
+			content: "foo: bar\n",
+			err:     "remove",
+		},
+		{
+			name: "insert after anchor",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "go.mod", Type: v1alpha.InsertPatchType,
+				Patch: "\tgithub.com/acme/lib v1.0.0", After: `^require \(`,
+			},
+			content:  "module app\n\nrequire (\n\tgithub.com/foo/bar v1.2.0\n)\n",
+			expected: "module app\n\nrequire (\n\tgithub.com/acme/lib v1.0.0\n\tgithub.com/foo/bar v1.2.0\n)\n",
+		},
+		{
+			name: "insert before anchor",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "go.mod", Type: v1alpha.InsertPatchType,
+				Patch: "\tgithub.com/acme/lib v1.0.0\n", Before: `^\)`,
+			},
+			content:  "module app\n\nrequire (\n\tgithub.com/foo/bar v1.2.0\n)\n",
+			expected: "module app\n\nrequire (\n\tgithub.com/foo/bar v1.2.0\n\tgithub.com/acme/lib v1.0.0\n)\n",
+		},
+		{
+			name: "insert is idempotent",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "go.mod", Type: v1alpha.InsertPatchType,
+				Patch: "\tgithub.com/foo/bar v1.2.0\n", Before: `^\)`,
+			},
+			content:  "require (\n\tgithub.com/foo/bar v1.2.0\n)\n",
+			expected: "require (\n\tgithub.com/foo/bar v1.2.0\n)\n",
+		},
+		{
+			name: "insert appends without anchor",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: ".gitignore", Type: v1alpha.InsertPatchType, Patch: "dist/\n",
+			},
+			content:  "node_modules/",
+			expected: "node_modules/\ndist/\n",
+		},
+		{
+			name: "insert fails on missing anchor",
+			patch: v1alpha.CodeTemplatePatchResult{
+				File: "go.mod", Type: v1alpha.InsertPatchType, Patch: "x\n", After: `^require \(`,
+			},
+			content: "module app\n",
+			err:     "anchor '^require \\(' not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := applyPatch(tt.patch, tt.content)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+}
+
+func TestPatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docker-compose.yaml"), []byte("services:\n  web:\n    image: web:1\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("dist/\n"), 0644))
+
+	r := &v1alpha.FormResultManifest{
+		Spec: v1alpha.FormResultSpec{
+			Result: map[string]any{"name": "db", "cache": false},
+		},
+	}
+	r.Status.SetCondition(v1alpha.CodeTemplateConsumptionReady, true)
+
+	ct := &v1alpha.CodeTemplateManifest{
+		Spec: v1alpha.CodeTemplateSpec{
+			Kind: "go-template",
+			GenerateFiles: []v1alpha.GenerateFile{
+				{File: "README.md", Template: "# {{ .name }}\n"},
+			},
+			PatchFiles: []v1alpha.PatchFile{
+				{File: "docker-compose.yaml", Type: v1alpha.YAMLMergeType, Template: "services:\n  {{ .name }}:\n    image: postgres:16\n"},
+				{File: "docker-compose.yaml", Type: v1alpha.JSONPatchType, Template: "- op: add\n  path: /services/{{ .name }}/restart\n  value: always\n"},
+				{File: "docker-compose.yaml", Type: v1alpha.YAMLMergeType, When: "result.cache", Template: "services:\n  cache:\n    image: redis\n"},
+				{File: ".gitignore", Type: v1alpha.InsertPatchType, Template: "dist/\n"},
+				{File: "missing.yaml", Type: v1alpha.YAMLMergeType, Template: "a: b\n", Optional: true},
+			},
+		},
+	}
+	ct.Status.SetCondition(core.ResourceReady, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+	require.NoError(t, g.Generate(r, ct))
+	require.Len(t, ct.Status.PatchedFiles, 4, "patch of false condition must be left out")
+
+	files, err := g.Diff(dir, &ct.Status)
+	require.NoError(t, err)
+
+	diffs := map[string]FileDiff{}
+	for _, f := range files {
+		diffs[f.Path] = f
+	}
+	assert.Equal(t, DiffModified, diffs["docker-compose.yaml"].Action)
+	assert.Contains(t, diffs["docker-compose.yaml"].UnifiedDiff, "+  db:\n+    image: postgres:16\n+    restart: always\n")
+	assert.Equal(t, DiffUnchanged, diffs[".gitignore"].Action)
+	assert.NotContains(t, diffs, "missing.yaml")
+
+	require.NoError(t, g.MakeFiles(dir, &ct.Status))
+
+	actions := []string{}
+	for _, p := range ct.Status.PatchedFiles {
+		actions = append(actions, p.Action)
+	}
+	assert.Equal(t, []string{v1alpha.FilePatched, v1alpha.FilePatched, v1alpha.FileUnchanged, v1alpha.FileSkipped}, actions)
+
+	b, err := os.ReadFile(filepath.Join(dir, "docker-compose.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "services:\n  web:\n    image: web:1\n  db:\n    image: postgres:16\n    restart: always\n", string(b))
+
+	info, err := os.Stat(filepath.Join(dir, "docker-compose.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "mode of the patched file must be retained")
+
+	_, err = os.Stat(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "optional patch must not create the file")
+
+	// the file to be patched must exist unless the patch is optional
+	ct.Spec.PatchFiles = []v1alpha.PatchFile{{File: "missing.yaml", Type: v1alpha.YAMLMergeType, Template: "a: b\n"}}
+	require.NoError(t, g.Generate(r, ct))
+	_, err = g.Diff(dir, &ct.Status)
+	assert.ErrorContains(t, err, "file to be patched does not exist")
+
+	// the generated file cannot be patched
+	ct.Spec.PatchFiles = []v1alpha.PatchFile{{File: "README.md", Type: v1alpha.InsertPatchType, Template: "x\n"}}
+	assert.ErrorContains(t, g.Generate(r, ct), "spec.patchFiles[0].file")
+}
+
+func TestPatchFilesAppliedOnce(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("hosts:\n- web\n"), 0644))
+
+	base := core.Base{
+		APIVersion: "alchemy.io/v1alpha",
+		Kind:       "Form",
+		Metadata:   core.Metadata{Name: "app", Namespace: "default"},
+	}
+	r, err := v1alpha.NewFormResult("app", "default", base, map[string]any{"name": "db"})
+	require.NoError(t, err)
+	r.Status.SetCondition(v1alpha.CodeTemplateConsumptionReady, true)
+
+	ct := &v1alpha.CodeTemplateManifest{
+		Base: base,
+		Spec: v1alpha.CodeTemplateSpec{
+			Kind: "go-template",
+			PatchFiles: []v1alpha.PatchFile{
+				{File: "values.yaml", Type: v1alpha.JSONPatchType, Template: "- op: add\n  path: /hosts/-\n  value: {{ .name }}\n"},
+			},
+		},
+	}
+	ct.Kind = "CodeTemplate"
+	ct.Status.SetCondition(core.ResourceReady, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	for range 2 {
+		require.NoError(t, g.Generate(r, ct))
+		require.NoError(t, g.MakeFiles(dir, &ct.Status))
+
+		lock, err := v1alpha.NewLock("test", v1alpha.FormManifest{Base: base}, *r, *ct)
+		require.NoError(t, err)
+		require.NoError(t, g.WriteLock(dir, lock))
+	}
+	assert.Equal(t, v1alpha.FileApplied, ct.Status.PatchedFiles[0].Action)
+
+	b, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "hosts:\n  - web\n  - db\n", string(b), "patch recorded in the lock must not be applied again")
+
+	// the applied patch is kept in the lock, so that it is never applied
+	// again by the later runs
+	lock, err := ReadLock(dir)
+	require.NoError(t, err)
+	assert.True(t, lock.Spec.Patched(ct.Status.PatchedFiles[0]))
+
+	files, err := g.Diff(dir, &ct.Status)
+	require.NoError(t, err)
+	assert.Empty(t, files, "applied patch must not be diffed")
+}
+
+func TestValidatePatchFiles(t *testing.T) {
+	m := &v1alpha.CodeTemplateManifest{
+		Base: core.Base{APIVersion: "alchemy.io/v1alpha", Kind: "CodeTemplate", Metadata: core.Metadata{Name: "patches"}},
+		Spec: v1alpha.CodeTemplateSpec{
+			Kind: "go-template",
+			PatchFiles: []v1alpha.PatchFile{
+				{File: "../escape.yaml", Type: v1alpha.YAMLMergeType, Template: "a: b"},
+				{File: "a.yaml", Type: "strategic", Template: "a: b"},
+				{File: "a.yaml", Type: v1alpha.YAMLMergeType, Template: "a: b", After: "x"},
+				{File: "go.mod", Type: v1alpha.InsertPatchType, Template: "x", After: "("},
+				{File: "go.mod", Type: v1alpha.InsertPatchType, Template: " "},
+			},
+		},
+	}
+
+	err := m.Validate()
+	require.Error(t, err)
+	for _, path := range []string{
+		"spec.patchFiles[0].file",
+		"spec.patchFiles[1].type",
+		"spec.patchFiles[2].after",
+		"spec.patchFiles[3].after",
+		"spec.patchFiles[4].template",
+	} {
+		assert.ErrorContains(t, err, path)
+	}
+}
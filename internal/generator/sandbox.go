@@ -0,0 +1,57 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+)
+
+// sandboxPath returns the path of the file under the output directory,
+// where the file must not escape the output directory, including via the
+// symlinks of the file or its parent directories.
+func sandboxPath(root string, file string) (string, error) {
+	err := v1alpha.ValidateFilePath(file)
+	if err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(root, file)
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if errors.Is(err, os.ErrNotExist) {
+		// nothing exists under the output directory yet
+		return target, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// symlinks are resolved from the deepest existing path, as the file
+	// and its parent directories may not exist yet.
+	existing, rest := target, ""
+	var realTarget string
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			realTarget = filepath.Join(resolved, rest)
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+
+	rel, err := filepath.Rel(realRoot, realTarget)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file path '%s' escapes the output directory via symlink", file)
+	}
+
+	return target, nil
+}
@@ -0,0 +1,85 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxPath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	require.NoError(t, os.Mkdir(filepath.Join(root, "sub"), 0755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "within")))
+
+	tests := []struct {
+		file    string
+		escapes bool
+	}{
+		{file: "a/b.txt"},
+		{file: "sub/new/b.txt"},
+		{file: "within/b.txt"},
+		{file: "a/../b.txt"},
+		{file: "../b.txt", escapes: true},
+		{file: "a/../../b.txt", escapes: true},
+		{file: "/etc/b.txt", escapes: true},
+		{file: "escape/b.txt", escapes: true},
+		{file: "escape/new/b.txt", escapes: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			target, err := sandboxPath(root, tt.file)
+			if tt.escapes {
+				assert.Error(t, err, "path must be rejected")
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(root, tt.file), target)
+		})
+	}
+}
+
+func TestGenerateTemplatedFilePath(t *testing.T) {
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	generate := func(name string) (*v1alpha.CodeTemplateManifest, error) {
+		r := &v1alpha.FormResultManifest{
+			Spec: v1alpha.FormResultSpec{
+				Result: map[string]any{"name": name},
+			},
+		}
+		r.Status.SetCondition(v1alpha.CodeTemplateConsumptionReady, true)
+
+		ct := &v1alpha.CodeTemplateManifest{
+			Spec: v1alpha.CodeTemplateSpec{
+				Kind: "go-template",
+				GenerateFiles: []v1alpha.GenerateFile{
+					{File: "src/{{ .name }}/main.go", Template: "package {{ .name }}"},
+				},
+			},
+		}
+		ct.Status.SetCondition(core.ResourceReady, true)
+
+		return ct, g.Generate(r, ct)
+	}
+
+	ct, err := generate("app")
+	require.NoError(t, err)
+	assert.Equal(t, "src/app/main.go", ct.Status.GeneratedCodeFiles[0].File)
+	assert.Equal(t, "package app", ct.Status.GeneratedCodeFiles[0].Code)
+
+	_, err = generate("../../..")
+	assert.ErrorContains(t, err, "escapes the output directory")
+}
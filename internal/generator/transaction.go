@@ -0,0 +1,306 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// fileTransaction makes files under the root directory all or nothing.
+// Files are staged into a temporary directory within the root first, then
+// committed with renames, where the replaced files are backed up so that
+// every change is rolled back on any failure.
+type fileTransaction struct {
+	fs   afero.Fs
+	root string
+	log  *logrus.Entry
+
+	staging string
+	backup  string
+
+	ops     []fileOp
+	journal []journalEntry
+
+	// createdDirs are directories created during commit, in the order of
+	// creation.
+	createdDirs []string
+
+	// rollbackFailed reports whether the replaced files are retained in
+	// the backup directory.
+	rollbackFailed bool
+}
+
+type fileOp struct {
+	file string
+
+	// staged is the path of the staged file, it is empty if the file is
+	// to be deleted.
+	staged string
+}
+
+type journalEntry struct {
+	target string
+
+	// backup is the path of the backup of the replaced file, it is empty
+	// if there is no file replaced.
+	backup string
+	placed bool
+}
+
+const (
+	stagingPrefix string = ".alchemy-staging-"
+	backupPrefix  string = ".alchemy-backup-"
+)
+
+func newFileTransaction(fs afero.Fs, root string, log *logrus.Entry) (*fileTransaction, error) {
+	err := fs.MkdirAll(root, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("unable to make directory '%s': %w", root, err)
+	}
+
+	cleanStaleDirs(fs, root, log)
+
+	// staging and backup directories are within the root, so that they
+	// are on the same filesystem for renames to be atomic.
+	staging, err := afero.TempDir(fs, root, stagingPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to make staging directory: %w", err)
+	}
+	backup, err := afero.TempDir(fs, root, backupPrefix)
+	if err != nil {
+		_ = fs.RemoveAll(staging)
+
+		return nil, fmt.Errorf("unable to make backup directory: %w", err)
+	}
+
+	return &fileTransaction{
+		fs:      fs,
+		root:    root,
+		log:     log,
+		staging: staging,
+		backup:  backup,
+	}, nil
+}
+
+// cleanStaleDirs removes the staging and backup directories left behind by
+// the interrupted runs. The backup directory that retains any file is kept
+// and warned about instead, as the file is replaced by the interrupted run
+// and is to be restored by the user.
+func cleanStaleDirs(fs afero.Fs, root string, log *logrus.Entry) {
+	staging, _ := afero.Glob(fs, filepath.Join(root, stagingPrefix+"*"))
+	for _, dir := range staging {
+		err := fs.RemoveAll(dir)
+		if err != nil {
+			log.WithError(err).Debugf("unable to remove stale staging directory '%s'", dir)
+		}
+	}
+
+	backups, _ := afero.Glob(fs, filepath.Join(root, backupPrefix+"*"))
+	for _, dir := range backups {
+		retained, err := hasFiles(fs, dir)
+		if err != nil {
+			log.WithError(err).Debugf("unable to inspect stale backup directory '%s'", dir)
+
+			continue
+		}
+		if retained {
+			log.Warnf("backup directory '%s' retains files replaced by an interrupted run, restore them then remove the directory", dir)
+
+			continue
+		}
+
+		err = fs.RemoveAll(dir)
+		if err != nil {
+			log.WithError(err).Debugf("unable to remove stale backup directory '%s'", dir)
+		}
+	}
+}
+
+// hasFiles reports whether the directory contains any file, directories
+// excluded.
+func hasFiles(fs afero.Fs, dir string) (bool, error) {
+	found := false
+	err := afero.Walk(fs, dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			found = true
+
+			return filepath.SkipAll
+		}
+
+		return nil
+	})
+
+	return found, err
+}
+
+// Write stages the content of the file with the mode, which is retained
+// when the file is committed.
+func (tx *fileTransaction) Write(file string, content []byte, mode os.FileMode) error {
+	staged := filepath.Join(tx.staging, file)
+
+	err := tx.fs.MkdirAll(filepath.Dir(staged), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	err = afero.WriteFile(tx.fs, staged, content, mode)
+	if err != nil {
+		return fmt.Errorf("unable to stage file '%s': %w", file, err)
+	}
+
+	// the mode of the written file is masked by umask
+	err = tx.fs.Chmod(staged, mode)
+	if err != nil {
+		return fmt.Errorf("unable to set mode of file '%s': %w", file, err)
+	}
+
+	tx.ops = append(tx.ops, fileOp{file: file, staged: staged})
+
+	return nil
+}
+
+// Delete stages the deletion of the file.
+func (tx *fileTransaction) Delete(file string) {
+	tx.ops = append(tx.ops, fileOp{file: file})
+}
+
+// Commit applies the staged changes, every change is rolled back should
+// any change fail.
+func (tx *fileTransaction) Commit() error {
+	for _, op := range tx.ops {
+		err := tx.apply(op)
+		if err != nil {
+			rollbackErr := tx.rollback()
+			if rollbackErr != nil {
+				tx.rollbackFailed = true
+
+				return errors.Join(
+					fmt.Errorf("unable to commit file '%s': %w", op.file, err),
+					fmt.Errorf("unable to roll back, replaced files are retained in '%s': %w", tx.backup, rollbackErr),
+				)
+			}
+
+			return fmt.Errorf("unable to commit file '%s', every change is rolled back: %w", op.file, err)
+		}
+	}
+
+	return nil
+}
+
+func (tx *fileTransaction) apply(op fileOp) error {
+	target := filepath.Join(tx.root, op.file)
+
+	exists, err := fileExists(tx.fs, target)
+	if err != nil {
+		return err
+	}
+
+	entry := journalEntry{target: target}
+	if exists {
+		backup := filepath.Join(tx.backup, op.file)
+
+		err := tx.fs.MkdirAll(filepath.Dir(backup), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = tx.fs.Rename(target, backup)
+		if err != nil {
+			return err
+		}
+		entry.backup = backup
+	}
+	tx.journal = append(tx.journal, entry)
+
+	if op.staged == "" {
+		tx.log.WithField("file", target).Tracef("file '%s' deleted", target)
+
+		return nil
+	}
+
+	err = tx.mkdirAll(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+
+	err = tx.fs.Rename(op.staged, target)
+	if err != nil {
+		return err
+	}
+	tx.journal[len(tx.journal)-1].placed = true
+
+	tx.log.WithField("file", target).Tracef("file '%s' committed", target)
+
+	return nil
+}
+
+// mkdirAll makes the directory along with its parents, whereby the
+// directories made are tracked for rollback.
+func (tx *fileTransaction) mkdirAll(dir string) error {
+	missing := []string{}
+	for d := dir; d != tx.root && d != filepath.Dir(d); d = filepath.Dir(d) {
+		exists, err := afero.Exists(tx.fs, d)
+		if err != nil {
+			return err
+		}
+		if exists {
+			break
+		}
+		missing = append(missing, d)
+	}
+
+	// directories are tracked even if they are partially made
+	err := tx.fs.MkdirAll(dir, os.ModePerm)
+	for i := len(missing) - 1; i >= 0; i-- {
+		exists, existsErr := afero.Exists(tx.fs, missing[i])
+		if existsErr != nil || !exists {
+			break
+		}
+		tx.createdDirs = append(tx.createdDirs, missing[i])
+	}
+
+	return err
+}
+
+func (tx *fileTransaction) rollback() error {
+	var errs error
+
+	for i := len(tx.journal) - 1; i >= 0; i-- {
+		entry := tx.journal[i]
+
+		if entry.placed {
+			err := tx.fs.Remove(entry.target)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = errors.Join(errs, err)
+			}
+		}
+		if entry.backup != "" {
+			errs = errors.Join(errs, tx.fs.Rename(entry.backup, entry.target))
+		}
+	}
+
+	for i := len(tx.createdDirs) - 1; i >= 0; i-- {
+		errs = errors.Join(errs, tx.fs.Remove(tx.createdDirs[i]))
+	}
+
+	tx.log.WithError(errs).Debug("file transaction rolled back")
+
+	return errs
+}
+
+// Close removes the staging directory, along with the backup directory
+// unless it retains the files failed to be rolled back.
+func (tx *fileTransaction) Close() {
+	_ = tx.fs.RemoveAll(tx.staging)
+
+	if !tx.rollbackFailed {
+		_ = tx.fs.RemoveAll(tx.backup)
+	}
+}
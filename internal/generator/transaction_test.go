@@ -0,0 +1,145 @@
+package generator
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRenameFs fails the rename into the target.
+type failingRenameFs struct {
+	afero.Fs
+	target string
+}
+
+func (fs failingRenameFs) Rename(oldname, newname string) error {
+	if newname == fs.target {
+		return errors.New("rename failed")
+	}
+
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func TestFileTransactionRollback(t *testing.T) {
+	root := "/project"
+	fs := failingRenameFs{
+		Fs:     afero.NewMemMapFs(),
+		target: filepath.Join(root, "fail.txt"),
+	}
+
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(root, "overwrite.txt"), []byte("old\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(root, "delete.txt"), []byte("old\n"), 0644))
+
+	tx, err := newFileTransaction(fs, root, utils.NewLogger())
+	require.NoError(t, err)
+
+	require.NoError(t, tx.Write("overwrite.txt", []byte("new\n"), 0644))
+	tx.Delete("delete.txt")
+	require.NoError(t, tx.Write("new/dir/created.txt", []byte("new\n"), 0644))
+	require.NoError(t, tx.Write("fail.txt", []byte("new\n"), 0644))
+
+	err = tx.Commit()
+	require.Error(t, err, "commit must fail")
+	assert.Contains(t, err.Error(), "every change is rolled back")
+
+	tx.Close()
+
+	b, err := afero.ReadFile(fs, filepath.Join(root, "overwrite.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "old\n", string(b), "overwritten file must be restored")
+
+	exists, err := afero.Exists(fs, filepath.Join(root, "delete.txt"))
+	require.NoError(t, err)
+	assert.True(t, exists, "deleted file must be restored")
+
+	entries, err := afero.ReadDir(fs, root)
+	require.NoError(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"overwrite.txt", "delete.txt"}, names,
+		"created directories, staging and backup must be removed")
+}
+
+func TestFileTransactionCommit(t *testing.T) {
+	root := "/project"
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(root, "overwrite.txt"), []byte("old\n"), 0644))
+
+	tx, err := newFileTransaction(fs, root, utils.NewLogger())
+	require.NoError(t, err)
+
+	require.NoError(t, tx.Write("overwrite.txt", []byte("new\n"), 0644))
+	require.NoError(t, tx.Write("new/created.txt", []byte("new\n"), 0644))
+	require.NoError(t, tx.Commit())
+
+	tx.Close()
+
+	for _, file := range []string{"overwrite.txt", "new/created.txt"} {
+		b, err := afero.ReadFile(fs, filepath.Join(root, file))
+		require.NoError(t, err)
+		assert.Equal(t, "new\n", string(b), "'%s' must be committed", file)
+	}
+
+	entries, err := afero.ReadDir(fs, root)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "staging and backup must be removed")
+}
+
+func TestFileTransactionDirectoryTarget(t *testing.T) {
+	root := "/project"
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(root, "config", "app.yaml"), []byte("keep\n"), 0644))
+
+	for name, stage := range map[string]func(tx *fileTransaction) error{
+		"write": func(tx *fileTransaction) error {
+			return tx.Write("config", []byte("new\n"), 0644)
+		},
+		"delete": func(tx *fileTransaction) error {
+			tx.Delete("config")
+
+			return nil
+		},
+	} {
+		tx, err := newFileTransaction(fs, root, utils.NewLogger())
+		require.NoError(t, err)
+
+		require.NoError(t, stage(tx))
+		err = tx.Commit()
+		assert.ErrorContains(t, err, "is a directory rather than a file", "%s must not replace directory", name)
+		tx.Close()
+
+		b, err := afero.ReadFile(fs, filepath.Join(root, "config", "app.yaml"))
+		require.NoError(t, err, "%s must keep the files of the directory", name)
+		assert.Equal(t, "keep\n", string(b))
+	}
+}
+
+func TestFileTransactionCleansStaleDirs(t *testing.T) {
+	root := "/project"
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(root, ".alchemy-staging-1", "a.txt"), []byte("staged\n"), 0644))
+	require.NoError(t, fs.MkdirAll(filepath.Join(root, ".alchemy-backup-1", "dir"), 0755))
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(root, ".alchemy-backup-2", "b.txt"), []byte("replaced\n"), 0644))
+
+	tx, err := newFileTransaction(fs, root, utils.NewLogger())
+	require.NoError(t, err)
+	tx.Close()
+
+	entries, err := afero.ReadDir(fs, root)
+	require.NoError(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{".alchemy-backup-2"}, names, "only the backup retaining files must be kept")
+}
@@ -0,0 +1,276 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+const (
+	UpgradeCreated    string = "created"
+	UpgradeUpdated    string = "updated"
+	UpgradeMerged     string = "merged"
+	UpgradeUnchanged  string = "unchanged"
+	UpgradeConflicted string = "conflicted"
+	UpgradeDeleted    string = "deleted"
+	UpgradeKept       string = "kept"
+
+	// UpgradeNew is the file new to the code template that exists
+	// locally without the locked content as the base of merge, it is kept
+	// as is rather than wrapped entirely in conflict markers.
+	UpgradeNew string = "new"
+)
+
+// UpgradeFile is the planned outcome of the file during upgrade.
+type UpgradeFile struct {
+	Path   string
+	Action string
+
+	// Content is the content to be written, it is irrelevant for both
+	// unchanged and kept files.
+	Content string
+
+	// Mode is the mode of the file to be written.
+	Mode os.FileMode
+
+	Conflicts int
+}
+
+// ReadLock reads the lock file from the directory of the generated code.
+func ReadLock(dir string) (*v1alpha.LockManifest, error) {
+	lock, err := readLock(afero.NewOsFs(), dir)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, fmt.Errorf("lock file '%s' not found, the code must be generated by `alchemy run` first", filepath.Join(dir, v1alpha.LockFileName))
+	}
+
+	return lock, nil
+}
+
+// readLock reads the lock file from the directory, where nil is returned if
+// the lock file does not exist.
+func readLock(fs afero.Fs, dir string) (*v1alpha.LockManifest, error) {
+	lockPath := filepath.Join(dir, v1alpha.LockFileName)
+
+	in, err := afero.ReadFile(fs, lockPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lock v1alpha.LockManifest
+	err = yaml.Unmarshal(in, &lock)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse lock file '%s': %w", lockPath, err)
+	}
+
+	err = lock.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid lock file '%s': %w", lockPath, err)
+	}
+
+	return &lock, nil
+}
+
+// PlanUpgrade plans the upgrade of the files of the directory from the
+// locked generation to the generated code of the status.
+//
+// Every file is merged three-way, where the locked content is the base, so
+// that the local edits are retained. Files removed from the code template
+// are deleted unless they are edited locally. The patches are applied on the
+// current files, except the patches recorded in the lock which are applied
+// already.
+func (g *v1alphaTemplateExecutor) PlanUpgrade(dir string, lock *v1alpha.LockManifest, s *v1alpha.CodeTemplateStatus) ([]UpgradeFile, error) {
+	if !s.GetCondition(v1alpha.CodeTemplateConsumptionDone) {
+		return nil, errCodeTemplateConsumptionNotDone
+	}
+
+	fs := afero.NewOsFs()
+
+	output := []UpgradeFile{}
+	upgradedPaths := map[string]bool{}
+
+	for _, f := range s.GeneratedCodeFiles {
+		if !f.IsWritten() {
+			continue
+		}
+		upgradedPaths[f.File] = true
+
+		log := g.log.WithField("file", f.File)
+
+		target, err := sandboxPath(dir, f.File)
+		if err != nil {
+			return nil, err
+		}
+
+		current, exists, err := readFile(fs, target)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := f.Content()
+		if err != nil {
+			return nil, err
+		}
+		code := string(content)
+
+		// the locked content is redacted (and empty for binary file),
+		// thus the files are compared with the locked checksum.
+		var base string
+		locked := lock.Spec.File(f.File)
+		hasBase := locked != nil && locked.Encoding == ""
+		if hasBase {
+			base = locked.Content
+		}
+
+		mode, err := fileMode(fs, target, f.Mode)
+		if err != nil {
+			return nil, err
+		}
+		currentMode, _, err := currentFileMode(fs, target)
+		if err != nil {
+			return nil, err
+		}
+
+		file := UpgradeFile{Path: f.File, Content: code, Mode: mode}
+
+		switch {
+		case !exists && locked != nil && locked.Checksum == v1alpha.Checksum(content):
+			// deleted locally and nothing to upgrade
+			file.Action = UpgradeKept
+		case !exists:
+			file.Action = UpgradeCreated
+		case current == code && currentMode == mode:
+			file.Action = UpgradeUnchanged
+		case current == code:
+			// only the mode is upgraded
+			file.Action = UpgradeUpdated
+		case locked != nil && locked.Checksum == v1alpha.Checksum([]byte(current)):
+			file.Action = UpgradeUpdated
+		case isBinary(current) || isBinary(code):
+			// binary file cannot be merged, the local edit is kept
+			file.Action = UpgradeKept
+			log.Warnf("binary file '%s' is edited locally, it is kept without upgrade", f.File)
+		case !hasBase:
+			file.Action = UpgradeNew
+			log.Warnf("file '%s' exists locally without locked content to merge with, it is kept without upgrade", f.File)
+		default:
+			file.Content, file.Conflicts = Merge3(base, current, code)
+			file.Action = UpgradeMerged
+			if file.Conflicts > 0 {
+				file.Action = UpgradeConflicted
+			}
+		}
+
+		log.Debugf("file '%s' is planned to be %s", f.File, file.Action)
+
+		output = append(output, file)
+	}
+
+	for _, locked := range lock.Spec.Files {
+		if upgradedPaths[locked.Path] {
+			continue
+		}
+
+		// the lock file could be edited by hand
+		target, err := sandboxPath(dir, locked.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		current, exists, err := readFile(fs, target)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		file := UpgradeFile{Path: locked.Path, Action: UpgradeDeleted}
+		if locked.Checksum != v1alpha.Checksum([]byte(current)) {
+			file.Action = UpgradeKept
+		}
+
+		output = append(output, file)
+	}
+
+	patched, err := g.applyPatches(fs, dir, lock, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range patched {
+		file := UpgradeFile{Path: f.path, Action: UpgradeUnchanged, Content: f.content, Mode: f.mode}
+		if f.content != f.current {
+			file.Action = UpgradeUpdated
+		}
+
+		output = append(output, file)
+	}
+
+	return output, nil
+}
+
+// ApplyUpgrade writes the planned files into the directory all or
+// nothing.
+func (g *v1alphaTemplateExecutor) ApplyUpgrade(dir string, files []UpgradeFile) error {
+	tx, err := newFileTransaction(afero.NewOsFs(), dir, g.log)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	for _, f := range files {
+		switch f.Action {
+		case UpgradeCreated, UpgradeUpdated, UpgradeMerged, UpgradeConflicted:
+			err := tx.Write(f.Path, []byte(f.Content), f.Mode)
+			if err != nil {
+				return err
+			}
+		case UpgradeDeleted:
+			tx.Delete(f.Path)
+		}
+
+		g.log.WithFields(logrus.Fields{
+			"file":   f.Path,
+			"action": f.Action,
+		}).Tracef("file '%s' is staged to be %s", f.Path, f.Action)
+	}
+
+	return tx.Commit()
+}
+
+// Conflicts returns the paths of the conflicted files as error.
+func Conflicts(files []UpgradeFile) error {
+	var errs error
+	for _, f := range files {
+		if f.Conflicts > 0 {
+			errs = errors.Join(errs, core.NewPathError(f.Path, fmt.Errorf("%d conflict(s) found", f.Conflicts)))
+		}
+	}
+
+	return errs
+}
+
+// readFile reads the file, it reports whether the file exists.
+func readFile(fs afero.Fs, filePath string) (content string, exists bool, err error) {
+	b, err := afero.ReadFile(fs, filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return string(b), true, nil
+}
@@ -0,0 +1,170 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/nicholastcs/alchemy/internal/apis/v1alpha"
+	"github.com/nicholastcs/alchemy/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLockT(t *testing.T, files ...v1alpha.CodeTemplateStatusResult) *v1alpha.LockManifest {
+	base := core.Base{
+		APIVersion: "alchemy.io/v1alpha",
+		Kind:       "Form",
+		Metadata:   core.Metadata{Name: "app", Namespace: "default"},
+	}
+
+	result, err := v1alpha.NewFormResult("app", "default", base, map[string]any{"token": "s3cr3t"})
+	require.NoError(t, err)
+	result.Spec.TypeHintByResult["token"] = v1alpha.SecretInputType
+
+	codeTemplate := v1alpha.CodeTemplateManifest{Base: base}
+	codeTemplate.Kind = "CodeTemplate"
+	codeTemplate.Status.GeneratedCodeFiles = files
+
+	lock, err := v1alpha.NewLock("test", v1alpha.FormManifest{Base: base}, *result, codeTemplate)
+	require.NoError(t, err)
+
+	return lock
+}
+
+func TestNewLockRedactsSecrets(t *testing.T) {
+	binary, encoding := v1alpha.EncodeContent([]byte{0, 1, 2})
+	require.Equal(t, "base64", encoding)
+
+	lock := newLockT(t,
+		v1alpha.CodeTemplateStatusResult{File: "config.yaml", Code: "token: s3cr3t\n"},
+		v1alpha.CodeTemplateStatusResult{File: "logo.png", Code: binary, Encoding: encoding},
+	)
+
+	assert.Equal(t, v1alpha.RedactedValue, lock.Spec.Result["token"])
+	assert.Equal(t, "token: <redacted>\n", lock.Spec.File("config.yaml").Content)
+	assert.Equal(t, v1alpha.Checksum([]byte("token: s3cr3t\n")), lock.Spec.File("config.yaml").Checksum,
+		"checksum must be of the generated content")
+	assert.Empty(t, lock.Spec.File("logo.png").Content, "binary content must not be locked")
+}
+
+func TestPlanUpgradeComparesChecksum(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("token: s3cr3t\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "removed.yaml"), []byte("token: s3cr3t\n"), 0644))
+
+	lock := newLockT(t,
+		v1alpha.CodeTemplateStatusResult{File: "config.yaml", Code: "token: s3cr3t\n"},
+		v1alpha.CodeTemplateStatusResult{File: "removed.yaml", Code: "token: s3cr3t\n"},
+	)
+
+	s := &v1alpha.CodeTemplateStatus{
+		GeneratedCodeFiles: []v1alpha.CodeTemplateStatusResult{
+			{File: "config.yaml", Code: "token: s3cr3t\nport: 8080\n"},
+		},
+	}
+	s.SetCondition(v1alpha.CodeTemplateConsumptionDone, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	files, err := g.PlanUpgrade(dir, lock, s)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	// unedited files are upgraded as is, despite of the redacted base
+	assert.Equal(t, UpgradeUpdated, files[0].Action)
+	assert.Equal(t, "token: s3cr3t\nport: 8080\n", files[0].Content)
+	assert.Equal(t, UpgradeDeleted, files[1].Action)
+}
+
+func TestPlanUpgradeNewFileAndPatches(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Makefile"), []byte("build:\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("bin/\n.env\n"), 0644))
+
+	lock := newLockT(t)
+
+	s := &v1alpha.CodeTemplateStatus{
+		GeneratedCodeFiles: []v1alpha.CodeTemplateStatusResult{
+			{File: "Makefile", Code: "test:\n"},
+		},
+		PatchedFiles: []v1alpha.CodeTemplatePatchResult{
+			// the patch applied already
+			{File: ".gitignore", Type: v1alpha.InsertPatchType, Patch: ".env"},
+			{File: ".gitignore", Type: v1alpha.InsertPatchType, Patch: "dist/"},
+		},
+	}
+	s.SetCondition(v1alpha.CodeTemplateConsumptionDone, true)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	files, err := g.PlanUpgrade(dir, lock, s)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	// the local file without locked base is never wrapped in conflict
+	// markers
+	assert.Equal(t, UpgradeNew, files[0].Action)
+	assert.Zero(t, files[0].Conflicts)
+
+	assert.Equal(t, ".gitignore", files[1].Path)
+	assert.Equal(t, UpgradeUpdated, files[1].Action)
+	assert.Equal(t, "bin/\n.env\ndist/\n", files[1].Content)
+
+	require.NoError(t, g.ApplyUpgrade(dir, files))
+
+	b, err := os.ReadFile(filepath.Join(dir, "Makefile"))
+	require.NoError(t, err)
+	assert.Equal(t, "build:\n", string(b))
+
+	b, err = os.ReadFile(filepath.Join(dir, ".gitignore"))
+	require.NoError(t, err)
+	assert.Equal(t, "bin/\n.env\ndist/\n", string(b))
+}
+
+func TestPlanUpgradeAppliesPatchesOnce(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("hosts:\n  - web\n"), 0644))
+
+	base := core.Base{
+		APIVersion: "alchemy.io/v1alpha",
+		Kind:       "Form",
+		Metadata:   core.Metadata{Name: "app", Namespace: "default"},
+	}
+	result, err := v1alpha.NewFormResult("app", "default", base, map[string]any{})
+	require.NoError(t, err)
+
+	codeTemplate := v1alpha.CodeTemplateManifest{Base: base}
+	codeTemplate.Kind = "CodeTemplate"
+
+	lock, err := v1alpha.NewLock("test", v1alpha.FormManifest{Base: base}, *result, codeTemplate)
+	require.NoError(t, err)
+
+	g, err := NewExecutor(utils.NewLogger())
+	require.NoError(t, err)
+
+	for range 2 {
+		codeTemplate.Status = v1alpha.CodeTemplateStatus{
+			PatchedFiles: []v1alpha.CodeTemplatePatchResult{
+				{File: "values.yaml", Type: v1alpha.JSONPatchType, Patch: "- op: add\n  path: /hosts/-\n  value: db\n"},
+			},
+		}
+		codeTemplate.Status.SetCondition(v1alpha.CodeTemplateConsumptionDone, true)
+
+		files, err := g.PlanUpgrade(dir, lock, &codeTemplate.Status)
+		require.NoError(t, err)
+		require.NoError(t, g.ApplyUpgrade(dir, files))
+
+		lock, err = v1alpha.NewLock("test", v1alpha.FormManifest{Base: base}, *result, codeTemplate)
+		require.NoError(t, err)
+		require.NoError(t, g.WriteLock(dir, lock))
+	}
+	assert.Equal(t, v1alpha.FileApplied, codeTemplate.Status.PatchedFiles[0].Action)
+
+	b, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "hosts:\n  - web\n  - db\n", string(b), "patch recorded in the lock must not be applied again")
+}
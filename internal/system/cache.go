@@ -4,34 +4,71 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"slices"
 
-	"github.com/maypok86/otter"
 	"github.com/nicholastcs/alchemy/internal/apis/core"
 
 	"github.com/sirupsen/logrus"
 )
 
 type Db struct {
-	cache otter.Cache[string, core.AbstractedManifest]
+	cache Store
 	log   *logrus.Entry
+
+	// persistent is the store that manifests of persistedAPIs are
+	// written through, it is nil if nothing is persisted.
+	persistent    Store
+	persistedAPIs []string
 }
 
 func NewLocalDB(log *logrus.Entry) (*Db, error) {
 	entry := log.WithField("context", "cache")
 
-	cache, err := otter.MustBuilder[string, core.AbstractedManifest](100).Build()
-	if err != nil {
-		return nil, err
-	}
-
 	entry.Trace("cache is ready")
 
 	return &Db{
-		cache: cache,
+		cache: NewMemoryStore(),
 		log:   log.WithField("context", "cache"),
 	}, nil
 }
 
+// NewPersistentDB returns the database where manifests of persistedAPIs
+// (in the form of `<apiVersion>/<kind>`) are written through into the
+// persistent store, whereas others are kept in memory only.
+//
+// Manifests persisted previously are loaded into the database.
+func NewPersistentDB(log *logrus.Entry, persistent Store, persistedAPIs []string) (*Db, error) {
+	if persistent == nil {
+		return nil, errors.New("persistent store cannot be nil")
+	}
+
+	db, err := NewLocalDB(log)
+	if err != nil {
+		return nil, err
+	}
+
+	db.persistent = persistent
+	db.persistedAPIs = persistedAPIs
+
+	var errs error
+	err = persistent.Range(func(key string, manifest core.AbstractedManifest) bool {
+		errs = errors.Join(errs, db.cache.Set(key, manifest))
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	db.log.Trace("persisted manifests are loaded")
+
+	return db, nil
+}
+
 func (db *Db) Set(manifest core.AbstractedManifest) error {
 	hash, err := db.manifestHash(manifest.Base)
 	if err != nil {
@@ -59,13 +96,21 @@ func (db *Db) Set(manifest core.AbstractedManifest) error {
 		"identity": manifest.Base,
 	})
 
-	outcome := db.cache.Set(hash, manifest)
-	if !outcome {
-		return errors.New("unable to add into cache")
+	err = db.cache.Set(hash, manifest)
+	if err != nil {
+		return fmt.Errorf("unable to add into cache: %w", err)
 	}
 
 	entry.Tracef("added '%s' under namespace '%s' into cache", manifest.Metadata.Name, manifest.Metadata.Namespace)
 
+	if db.isPersisted(manifest.Base) {
+		err = db.persistent.Set(hash, manifest)
+		if err != nil {
+			return fmt.Errorf("unable to persist '%s' under namespace '%s': %w",
+				manifest.Metadata.Name, manifest.Metadata.Namespace, err)
+		}
+	}
+
 	return nil
 }
 
@@ -101,20 +146,52 @@ func (db *Db) Get(apiVersion string, kind string, name string, namespace string)
 		"identity": base,
 	})
 
-	value, outcome := db.cache.Get(hash)
-	if !outcome {
+	value, err := db.cache.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
 		return nil, nil
 	}
 
 	entry.Tracef("got '%s' under namespace '%s' from cache", name, namespace)
 
-	return &value, nil
+	return value, nil
+}
+
+// Delete removes the manifest from the database, including the persistent
+// store if it is persisted.
+func (db *Db) Delete(apiVersion string, kind string, name string, namespace string) error {
+	base := core.Base{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Metadata: core.Metadata{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+
+	hash, err := db.manifestHash(base)
+	if err != nil {
+		return err
+	}
+
+	err = db.cache.Delete(hash)
+	if err != nil {
+		return err
+	}
+
+	if db.isPersisted(base) {
+		return db.persistent.Delete(hash)
+	}
+
+	return nil
 }
 
 func (db *Db) GetByGVKNs(apiVersion string, kind string, namespace string) ([]core.AbstractedManifest, error) {
 	output := []core.AbstractedManifest{}
 
-	db.cache.Range(
+	err := db.cache.Range(
 		func(k string, v core.AbstractedManifest) bool {
 			if v.APIVersion == apiVersion &&
 				v.Metadata.Namespace == namespace &&
@@ -126,13 +203,13 @@ func (db *Db) GetByGVKNs(apiVersion string, kind string, namespace string) ([]co
 		},
 	)
 
-	return output, nil
+	return output, err
 }
 
 func (db *Db) GetByGVK(apiVersion string, kind string) ([]core.AbstractedManifest, error) {
 	output := []core.AbstractedManifest{}
 
-	db.cache.Range(
+	err := db.cache.Range(
 		func(k string, v core.AbstractedManifest) bool {
 			if v.APIVersion == apiVersion &&
 				v.Kind == kind {
@@ -143,19 +220,27 @@ func (db *Db) GetByGVK(apiVersion string, kind string) ([]core.AbstractedManifes
 		},
 	)
 
-	return output, nil
+	return output, err
 }
 
 func (db *Db) Dump() ([]core.AbstractedManifest, error) {
 	output := []core.AbstractedManifest{}
 
-	db.cache.Range(func(_ string, v core.AbstractedManifest) bool {
+	err := db.cache.Range(func(_ string, v core.AbstractedManifest) bool {
 		output = append(output, v)
 
 		return true
 	})
 
-	return output, nil
+	return output, err
+}
+
+func (db *Db) isPersisted(manifest core.Base) bool {
+	if db.persistent == nil {
+		return false
+	}
+
+	return slices.Contains(db.persistedAPIs, fmt.Sprintf("%s/%s", manifest.APIVersion, manifest.Kind))
 }
 
 func (db *Db) manifestHash(manifest core.Base) (string, error) {
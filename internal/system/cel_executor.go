@@ -2,28 +2,37 @@ package system
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/samber/lo"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
 	manifest       executionEnv = "manifest"
 	formValidation executionEnv = "formValidation"
+	formCondition  executionEnv = "formCondition"
+	codeTemplate   executionEnv = "codeTemplate"
 )
 
 var (
 	cache = cachedProgramsByEnv{
 		manifest:       map[string]cel.Program{},
 		formValidation: map[string]cel.Program{},
+		formCondition:  map[string]cel.Program{},
+		codeTemplate:   map[string]cel.Program{},
 	}
 
 	manifestEnv       *cel.Env
 	formValidationEnv *cel.Env
+	formConditionEnv  *cel.Env
+	codeTemplateEnv   *cel.Env
 )
 
 type executionEnv string
@@ -60,9 +69,23 @@ func init() {
 		k8sParseQuantity,
 	}...)
 
+	// answers are dynamically typed, so that the answers of list and
+	// key-value-list can be used in macros.
 	formValidationEnv, _ = cel.NewEnv([]cel.EnvOption{
-		cel.Variable("this", cel.AnyType),
-		cel.Variable("result", cel.MapType(cel.StringType, cel.AnyType)),
+		cel.Variable("this", cel.DynType),
+		cel.Variable("result", cel.MapType(cel.StringType, cel.DynType)),
+		k8sParseQuantity,
+	}...)
+
+	formConditionEnv, _ = cel.NewEnv([]cel.EnvOption{
+		cel.Variable("result", cel.MapType(cel.StringType, cel.DynType)),
+		k8sParseQuantity,
+	}...)
+
+	// answers are dynamically typed, so that they can be used as list
+	// or map in macros.
+	codeTemplateEnv, _ = cel.NewEnv([]cel.EnvOption{
+		cel.Variable("result", cel.MapType(cel.StringType, cel.DynType)),
 		k8sParseQuantity,
 	}...)
 }
@@ -149,3 +172,99 @@ func ExecuteCELOnFormValidation(input map[string]interface{}, celExpression stri
 
 	return outcome, nil
 }
+
+// ExecuteCELOnFormCondition evaluates the condition of the field, such as
+// `showWhen`, over the answers of the form in progress (`result`).
+func ExecuteCELOnFormCondition(result map[string]any, celExpression string) (bool, error) {
+	out, err := ExecuteCELOnForm(result, celExpression)
+	if err != nil {
+		return false, err
+	}
+
+	outcome, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("output type must be boolean, but found '%s'", out.Type().TypeName())
+	}
+
+	return outcome, nil
+}
+
+// ExecuteCELOnForm evaluates the CEL expression over the answers of the
+// form in progress (`result`), such as `defaultExpr` of the field.
+func ExecuteCELOnForm(result map[string]any, celExpression string) (ref.Val, error) {
+	input := map[string]any{"result": result}
+
+	program, ok := cache[formCondition][celExpression]
+	if !ok {
+		ast, issues := formConditionEnv.Compile(celExpression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("type-check error found: %w", issues.Err())
+		}
+
+		var err error
+		program, err = formConditionEnv.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("program construction error: %s", err)
+		}
+
+		cache[formCondition][celExpression] = program
+	}
+
+	out, _, err := program.Eval(input)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation error: %s", err)
+	}
+
+	return out, nil
+}
+
+// ExecuteCELOnResult evaluates the CEL expression over the answers of the
+// form result, which is `result`, along with the additional variables such
+// as the element of the loop.
+func ExecuteCELOnResult(result map[string]any, vars map[string]any, celExpression string) (ref.Val, error) {
+	names := lo.Keys(vars)
+	slices.Sort(names)
+
+	input := lo.Assign(vars, map[string]any{"result": result})
+
+	// the same expression compiles differently by the variables declared
+	key := strings.Join(append(names, celExpression), "\x00")
+	if program, ok := cache[codeTemplate][key]; ok {
+		out, _, err := program.Eval(input)
+		if err != nil {
+			return nil, fmt.Errorf("evaluation error: %s", err)
+		}
+
+		return out, nil
+	}
+
+	env := codeTemplateEnv
+	if len(names) > 0 {
+		var err error
+		env, err = codeTemplateEnv.Extend(lo.Map(names, func(name string, _ int) cel.EnvOption {
+			return cel.Variable(name, cel.DynType)
+		})...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ast, issues := env.Compile(celExpression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("type-check error found: %w", issues.Err())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("program construction error: %s", err)
+	}
+
+	out, _, err := program.Eval(input)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation error: %s", err)
+	}
+
+	cache[codeTemplate][key] = program
+
+	return out, nil
+}
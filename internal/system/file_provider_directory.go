@@ -0,0 +1,39 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewDirectoryLoader returns the manifest source provider that reads
+// manifests from a directory on the host filesystem at runtime, thus
+// changes on manifests do not require the CLI to be rebuilt.
+func NewDirectoryLoader(dir string, allowedAPIs []string, log *logrus.Entry) (*staticManifestSourceProvider, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("directory cannot be empty")
+	}
+
+	absPath, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest directory '%s': %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("manifest source '%s' is not a directory", dir)
+	}
+
+	return &staticManifestSourceProvider{
+		fs:          os.DirFS(absPath),
+		allowedAPIs: allowedAPIs,
+		log:         log.WithField("context", "readManifests"),
+		name:        "directory",
+		root:        absPath,
+	}, nil
+}
@@ -3,19 +3,28 @@ package system
 import (
 	"fmt"
 	"io/fs"
-	"slices"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/nicholastcs/alchemy/internal/apis/core"
 
-	"github.com/goccy/go-yaml"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/afero"
 )
 
 type staticManifestSourceProvider struct {
 	fs          fs.FS
 	allowedAPIs []string
 	log         *logrus.Entry
+
+	// name is the name of the provider.
+	name string
+
+	// root is the actual directory of the fs on the host, it is empty
+	// when the fs is not backed by the host filesystem (e.g. embed).
+	root string
+
+	filter ManifestFilter
 }
 
 func NewFileLoader(fs fs.FS, allowedAPIs []string, log *logrus.Entry) (*staticManifestSourceProvider, error) {
@@ -23,52 +32,97 @@ func NewFileLoader(fs fs.FS, allowedAPIs []string, log *logrus.Entry) (*staticMa
 		fs:          fs,
 		allowedAPIs: allowedAPIs,
 		log:         log.WithField("context", "readManifests"),
+		name:        "static",
 	}, nil
 }
 
+// GetFiles walks the uri recursively, and reads every file included by
+// the filter as manifests. A file could contain multiple YAML documents.
 func (p *staticManifestSourceProvider) GetFiles(uri string) ([]core.AbstractedManifest, error) {
-	iofs := afero.FromIOFS{
-		FS: p.fs,
-	}
-
 	output := []core.AbstractedManifest{}
 
-	// TODO: we take in "embed" as a standardized directory for now
-	fileInfos, err := afero.ReadDir(iofs, uri)
-	if err != nil {
-		return nil, err
-	}
+	err := fs.WalkDir(p.fs, uri, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 
-	for _, fileInfo := range fileInfos {
-		if !fileInfo.IsDir() {
-			in, err := afero.ReadFile(iofs, uri+"/"+fileInfo.Name())
-			if err != nil {
-				return nil, err
-			}
+		if filePath == uri {
+			return nil
+		}
 
-			var out core.AbstractedManifest
-			err = yaml.Unmarshal(in, &out)
-			if err != nil {
-				return nil, err
-			}
+		relPath := filePath
+		if uri != "." {
+			relPath = strings.TrimPrefix(filePath, uri+"/")
+		}
 
-			// check for API, discard silently if they are not relevant
-			api := fmt.Sprintf("%s/%s", out.APIVersion, out.Kind)
-			isAllowed := slices.Contains(p.allowedAPIs, api)
-			if !isAllowed {
-				p.log.WithField("illegalAPI", api).Warnf("illegal API '%s' found, skipping...", api)
-				continue
+		if d.IsDir() {
+			if p.filter.Excluded(relPath) {
+				return fs.SkipDir
 			}
 
-			out.SetFilePath(fileInfo.Name())
+			return nil
+		}
 
-			output = append(output, out)
+		if !p.filter.Included(relPath) {
+			p.log.WithField("file", filePath).Tracef("file '%s' is not included, skipping...", filePath)
+			return nil
 		}
+
+		in, err := fs.ReadFile(p.fs, filePath)
+		if err != nil {
+			return err
+		}
+
+		manifests, err := decodeManifests(in, p.filePath(uri, relPath), p.allowedAPIs, p.log)
+		if err != nil {
+			return err
+		}
+
+		output = append(output, manifests...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return output, nil
 }
 
+// WithFilter sets the filter of files to be read as manifests.
+func (p *staticManifestSourceProvider) WithFilter(filter ManifestFilter) *staticManifestSourceProvider {
+	p.filter = filter
+
+	return p
+}
+
 func (p *staticManifestSourceProvider) Name() string {
-	return "static"
+	return p.name
+}
+
+// Assets returns the fs rooted at the directory of the manifest file.
+func (p *staticManifestSourceProvider) Assets(uri string, filePath string) (fs.FS, error) {
+	if p.root == "" {
+		return fs.Sub(p.fs, path.Join(uri, path.Dir(filePath)))
+	}
+
+	rel, err := filepath.Rel(p.root, filepath.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+	if !fs.ValidPath(filepath.ToSlash(rel)) {
+		return nil, fmt.Errorf("file '%s' is not within the manifest directory '%s'", filePath, p.root)
+	}
+
+	return fs.Sub(p.fs, filepath.ToSlash(rel))
+}
+
+// filePath returns the path of the file to be annotated on the manifest,
+// which is the actual path on the host if the fs is backed by it.
+func (p *staticManifestSourceProvider) filePath(uri, relPath string) string {
+	if p.root == "" {
+		return relPath
+	}
+
+	return filepath.Join(p.root, uri, relPath)
 }
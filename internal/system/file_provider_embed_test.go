@@ -1,6 +1,11 @@
 package system
 
 import (
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -88,3 +93,152 @@ func TestNewFileLoaderFull(t *testing.T) {
 	// actual manifest count is 1
 	assert.Equal(t, len(manifests), 1)
 }
+
+func TestFileLoaderRecursiveMultiDocument(t *testing.T) {
+	uFs := afero.NewMemMapFs()
+
+	// both documents of the file are loaded, whereas the illegal kind is
+	// discarded
+	err := afero.WriteFile(uFs, "embed/nested/manifests.yaml",
+		[]byte("# comment only document\n---\n"+file1+"---\n"+file2+"---\n"+strings.ReplaceAll(file1, "test-template", "test-template-2")), 0644)
+	require.NoError(t, err)
+
+	err = afero.WriteFile(uFs, "embed/drafts/code-template.yaml", []byte(file1), 0644)
+	require.NoError(t, err)
+
+	err = afero.WriteFile(uFs, "embed/README.md", []byte("# not a manifest"), 0644)
+	require.NoError(t, err)
+
+	provider, err := NewFileLoader(afero.NewIOFS(uFs), []string{
+		"alchemy.io/v1alpha/CodeTemplate",
+	}, logT)
+	require.NoError(t, err)
+
+	filter, err := NewManifestFilter(url.Values{"exclude": []string{"drafts"}})
+	require.NoError(t, err)
+
+	manifests, err := provider.WithFilter(filter).GetFiles("embed")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(manifests))
+
+	assert.Equal(t, "test-template", manifests[0].Metadata.Name)
+	assert.Equal(t, "nested/manifests.yaml", manifests[0].GetFilePath())
+	assert.Equal(t, "0", manifests[0].GetDocumentIndex())
+	assert.Equal(t, "test-template-2", manifests[1].Metadata.Name)
+	assert.Equal(t, "2", manifests[1].GetDocumentIndex())
+}
+
+func TestSplitDocuments(t *testing.T) {
+	testCases := []struct {
+		in        string
+		documents []string
+	}{
+		{
+			// leading comments are not a document
+			in:        "# comment\n---\na: 1\n",
+			documents: []string{"a: 1\n"},
+		},
+		{
+			in:        "a: 1\n",
+			documents: []string{"a: 1\n"},
+		},
+		{
+			// separator within block scalar is the content
+			in:        "a: |\n  x\n  ---\n  y\n---\nb: 2\n",
+			documents: []string{"a: |\n  x\n  ---\n  y\n", "b: 2\n"},
+		},
+		{
+			// empty and comment-only documents are counted
+			in:        "a: 1\n---\n---\n# comment\n--- # trailing\nb: 2\n",
+			documents: []string{"a: 1\n", "", "# comment\n", "b: 2\n"},
+		},
+		{
+			in:        "",
+			documents: []string{},
+		},
+	}
+
+	for _, u := range testCases {
+		documents := []string{}
+		for _, document := range SplitDocuments([]byte(u.in)) {
+			documents = append(documents, string(document))
+		}
+
+		assert.Equal(t, u.documents, documents, "input %q", u.in)
+	}
+}
+
+func TestFileLoaderAssets(t *testing.T) {
+	uFs := afero.NewMemMapFs()
+
+	err := afero.WriteFile(uFs, "embed/nested/code-template.yaml", []byte(file1), 0644)
+	require.NoError(t, err)
+	err = afero.WriteFile(uFs, "embed/nested/seed/README.md", []byte("# seed"), 0644)
+	require.NoError(t, err)
+
+	provider, err := NewFileLoader(afero.NewIOFS(uFs), []string{
+		"alchemy.io/v1alpha/CodeTemplate",
+	}, logT)
+	require.NoError(t, err)
+
+	manifests, err := provider.GetFiles("embed")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(manifests))
+
+	// files are located relative to the manifest file
+	assets, err := provider.Assets("embed", manifests[0].GetFilePath())
+	require.NoError(t, err)
+
+	b, err := fs.ReadFile(assets, "seed/README.md")
+	require.NoError(t, err)
+	assert.Equal(t, "# seed", string(b))
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested", "seed"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "code-template.yaml"), []byte(file1), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "seed", "README.md"), []byte("# seed"), 0644))
+
+	source, err := NewManifestSource("dir://"+dir, []string{"alchemy.io/v1alpha/CodeTemplate"}, logT)
+	require.NoError(t, err)
+
+	manifests, err = source.Load()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(manifests))
+
+	assets, err = source.Assets(manifests[0].GetFilePath())
+	require.NoError(t, err)
+
+	b, err = fs.ReadFile(assets, "seed/README.md")
+	require.NoError(t, err)
+	assert.Equal(t, "# seed", string(b))
+
+	source, err = NewManifestSource("https://example.com/code-template.yaml", []string{}, logT)
+	require.NoError(t, err)
+
+	_, err = source.Assets("https://example.com/code-template.yaml")
+	assert.Error(t, err, "http source must not serve files other than manifests")
+}
+
+func TestManifestFilter(t *testing.T) {
+	testCases := []struct {
+		include []string
+		exclude []string
+		path    string
+		output  bool
+	}{
+		{path: "form.yaml", output: true},
+		{path: "a/b/form.yml", output: true},
+		{path: "a/b/form.json", output: false},
+		{include: []string{"forms/**/*.yaml"}, path: "forms/form.yaml", output: true},
+		{include: []string{"forms/**/*.yaml"}, path: "forms/a/b/form.yaml", output: true},
+		{include: []string{"forms/**/*.yaml"}, path: "templates/form.yaml", output: false},
+		{exclude: []string{"*.draft.yaml"}, path: "a/form.draft.yaml", output: false},
+		{exclude: []string{"a/**"}, path: "a/b/form.yaml", output: false},
+	}
+
+	for _, u := range testCases {
+		f := ManifestFilter{Include: u.include, Exclude: u.exclude}
+
+		assert.Equal(t, u.output, f.Included(u.path), "path '%s'", u.path)
+	}
+}
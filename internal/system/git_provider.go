@@ -0,0 +1,238 @@
+package system
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/sirupsen/logrus"
+)
+
+// gitManifestSourceProvider is the manifest source provider that reads
+// manifests from a Git repository. The repository is cloned (or fetched
+// if it was cloned previously) into the cache directory, and checked out
+// to the pinned ref before manifests are loaded.
+type gitManifestSourceProvider struct {
+	remote      string
+	ref         string
+	cacheDir    string
+	allowedAPIs []string
+	log         *logrus.Entry
+	filter      ManifestFilter
+}
+
+// NewGitLoader returns the manifest source provider for Git repository.
+//
+// Remote could be any remote supported by Git, such as local path or
+// `file://` remote. Ref could be branch, tag or commit, it takes the
+// default branch of the remote if it is empty.
+func NewGitLoader(remote, ref, cacheDir string, allowedAPIs []string, log *logrus.Entry) (*gitManifestSourceProvider, error) {
+	if remote == "" {
+		return nil, errors.New("git remote cannot be empty")
+	}
+	if cacheDir == "" {
+		return nil, errors.New("git cache directory cannot be empty")
+	}
+
+	_, err := exec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("git executable is required for git manifest source: %w", err)
+	}
+
+	err = validateRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// local path is made absolute, so that the same repository shares
+	// the same cache regardless of the working directory.
+	if _, err := os.Stat(remote); err == nil {
+		remote, err = filepath.Abs(remote)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &gitManifestSourceProvider{
+		remote:      remote,
+		ref:         ref,
+		cacheDir:    cacheDir,
+		allowedAPIs: allowedAPIs,
+		log: log.WithFields(logrus.Fields{
+			"context": "readManifests",
+			"remote":  remote,
+			"ref":     ref,
+		}),
+	}, nil
+}
+
+// validateRef validates the ref is a well-formed branch, tag or commit,
+// which never reads as an option of git.
+func validateRef(ref string) error {
+	if ref == "" {
+		return nil
+	}
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("git ref '%s' cannot start with '-'", ref)
+	}
+
+	err := exec.Command("git", "check-ref-format", "--allow-onelevel", ref).Run()
+	if err != nil {
+		return fmt.Errorf("git ref '%s' is malformed: %w", ref, err)
+	}
+
+	return nil
+}
+
+// DefaultGitCacheDir returns the directory where Git repositories are
+// cloned into, which is under the user cache directory.
+func DefaultGitCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "alchemy", "git"), nil
+}
+
+// GetFiles syncs the repository, then reads manifests from the uri, which
+// is the subdirectory relative to the root of the repository.
+func (p *gitManifestSourceProvider) GetFiles(uri string) ([]core.AbstractedManifest, error) {
+	dir, err := p.sync()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, uri)
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || !fs.ValidPath(filepath.ToSlash(rel)) {
+		return nil, fmt.Errorf("directory '%s' is not within the git repository", uri)
+	}
+
+	loader, err := NewDirectoryLoader(path, p.allowedAPIs, p.log)
+	if err != nil {
+		return nil, err
+	}
+
+	return loader.WithFilter(p.filter).GetFiles(".")
+}
+
+// Assets returns the fs rooted at the directory of the manifest file,
+// which is within the repository synced by GetFiles.
+func (p *gitManifestSourceProvider) Assets(_ string, filePath string) (fs.FS, error) {
+	rel, err := filepath.Rel(p.cacheDir, filePath)
+	if err != nil || !fs.ValidPath(filepath.ToSlash(rel)) {
+		return nil, fmt.Errorf("file '%s' is not within the git cache directory '%s'", filePath, p.cacheDir)
+	}
+
+	return os.DirFS(filepath.Dir(filePath)), nil
+}
+
+// WithFilter sets the filter of files to be read as manifests.
+func (p *gitManifestSourceProvider) WithFilter(filter ManifestFilter) *gitManifestSourceProvider {
+	p.filter = filter
+
+	return p
+}
+
+func (p *gitManifestSourceProvider) Name() string {
+	return "git"
+}
+
+// sync clones the repository into the cache directory, or fetches the
+// latest changes if it was cloned, then checks out the pinned ref.
+//
+// Each ref has its own checkout, so that sources of the same repository
+// at different refs never read the files of each other.
+func (p *gitManifestSourceProvider) sync() (string, error) {
+	h := sha1.New()
+	h.Write([]byte(p.remote + "\x00" + p.ref))
+	dir := filepath.Join(p.cacheDir, hex.EncodeToString(h.Sum(nil)))
+
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		err = os.MkdirAll(p.cacheDir, os.ModePerm)
+		if err != nil {
+			return "", err
+		}
+
+		_, err = p.git("", "clone", "--quiet", "--no-checkout", "--", p.remote, dir)
+		if err != nil {
+			return "", err
+		}
+
+		p.log.Debugf("cloned '%s' into '%s'", p.remote, dir)
+	case err != nil:
+		return "", err
+	default:
+		_, err = p.git(dir, "fetch", "--quiet", "--tags", "--force", "--prune", "origin")
+		if err != nil {
+			return "", err
+		}
+
+		p.log.Debugf("fetched '%s' into '%s'", p.remote, dir)
+	}
+
+	commit, err := p.resolve(dir)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = p.git(dir, "checkout", "--quiet", "--force", "--detach", commit)
+	if err != nil {
+		return "", err
+	}
+
+	p.log.Debugf("checked out '%s' at commit '%s'", p.remote, commit)
+
+	return dir, nil
+}
+
+// resolve resolves the pinned ref into commit. Remote branches take
+// precedence over tags and commits, so that the latest of the branch is
+// always checked out.
+func (p *gitManifestSourceProvider) resolve(dir string) (string, error) {
+	candidates := []string{"origin/HEAD"}
+	if p.ref != "" {
+		candidates = []string{"origin/" + p.ref, p.ref}
+	}
+
+	for _, candidate := range candidates {
+		commit, err := p.git(dir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}")
+		if err == nil {
+			return commit, nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to resolve ref '%s' of git remote '%s'", p.ref, p.remote)
+}
+
+func (p *gitManifestSourceProvider) git(dir string, args ...string) (string, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// never prompts for credentials, as it would hang the CLI
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("unable to run 'git %s': %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,142 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gitT(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	args = append([]string{"-C", dir, "-c", "user.name=alchemy", "-c", "user.email=alchemy@localhost"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestGitLoaderRefPinning(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git executable not found")
+	}
+
+	repo := t.TempDir()
+	gitT(t, repo, "init", "--quiet", "--initial-branch=main")
+
+	err := os.MkdirAll(filepath.Join(repo, "manifests"), 0755)
+	require.NoError(t, err)
+
+	manifest := filepath.Join(repo, "manifests", "code-template.yaml")
+	err = os.WriteFile(manifest, []byte(file1), 0644)
+	require.NoError(t, err)
+	gitT(t, repo, "add", "-A")
+	gitT(t, repo, "commit", "--quiet", "-m", "v1")
+	gitT(t, repo, "tag", "v1")
+
+	err = os.WriteFile(manifest, []byte(strings.ReplaceAll(file1, "5.72.1", "5.80.0")), 0644)
+	require.NoError(t, err)
+	gitT(t, repo, "commit", "--quiet", "-am", "v2")
+
+	allowedAPIs := []string{"alchemy.io/v1alpha/CodeTemplate"}
+	cacheDir := t.TempDir()
+
+	testCases := []struct {
+		ref     string
+		version string
+	}{
+		{ref: "v1", version: "5.72.1"},
+		{ref: "main", version: "5.80.0"},
+		{ref: "", version: "5.80.0"},
+	}
+
+	for _, u := range testCases {
+		provider, err := NewGitLoader("file://"+repo, u.ref, cacheDir, allowedAPIs, logT)
+		require.NoError(t, err)
+
+		manifests, err := provider.GetFiles("manifests")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(manifests))
+
+		assert.Contains(t, fmt.Sprintf("%v", manifests[0].Spec), u.version, "ref '%s'", u.ref)
+	}
+
+	provider, err := NewGitLoader("file://"+repo, "unknown", cacheDir, allowedAPIs, logT)
+	require.NoError(t, err)
+
+	_, err = provider.GetFiles("manifests")
+	assert.Error(t, err)
+}
+
+func TestGitLoaderCheckoutPerRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git executable not found")
+	}
+
+	repo := t.TempDir()
+	gitT(t, repo, "init", "--quiet", "--initial-branch=main")
+
+	file := filepath.Join(repo, "version.txt")
+	err := os.WriteFile(file, []byte("v1"), 0644)
+	require.NoError(t, err)
+	gitT(t, repo, "add", "-A")
+	gitT(t, repo, "commit", "--quiet", "-m", "v1")
+	gitT(t, repo, "tag", "v1")
+
+	err = os.WriteFile(file, []byte("v2"), 0644)
+	require.NoError(t, err)
+	gitT(t, repo, "commit", "--quiet", "-am", "v2")
+
+	cacheDir := t.TempDir()
+
+	v1, err := NewGitLoader("file://"+repo, "v1", cacheDir, nil, logT)
+	require.NoError(t, err)
+	v1Dir, err := v1.sync()
+	require.NoError(t, err)
+
+	main, err := NewGitLoader("file://"+repo, "main", cacheDir, nil, logT)
+	require.NoError(t, err)
+	mainDir, err := main.sync()
+	require.NoError(t, err)
+
+	// the later sync of main never checks out over the files of v1
+	b, err := os.ReadFile(filepath.Join(v1Dir, "version.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(b))
+
+	b, err = os.ReadFile(filepath.Join(mainDir, "version.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(b))
+}
+
+func TestGitLoaderRejectsUnsafeInput(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git executable not found")
+	}
+
+	repo := t.TempDir()
+	gitT(t, repo, "init", "--quiet", "--initial-branch=main")
+	gitT(t, repo, "commit", "--quiet", "--allow-empty", "-m", "v1")
+
+	cacheDir := t.TempDir()
+
+	for _, ref := range []string{"--upload-pack=touch", "main..v1", "a b"} {
+		_, err := NewGitLoader("file://"+repo, ref, cacheDir, nil, logT)
+		assert.Error(t, err, "ref '%s'", ref)
+	}
+
+	// remote reads never as an option of git clone
+	provider, err := NewGitLoader("--upload-pack=touch", "", cacheDir, nil, logT)
+	require.NoError(t, err)
+	_, err = provider.GetFiles(".")
+	assert.Error(t, err)
+
+	provider, err = NewGitLoader("file://"+repo, "main", cacheDir, nil, logT)
+	require.NoError(t, err)
+	_, err = provider.GetFiles("../..")
+	assert.ErrorContains(t, err, "not within the git repository")
+}
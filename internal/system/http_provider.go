@@ -0,0 +1,53 @@
+package system
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/sirupsen/logrus"
+)
+
+// httpManifestSourceProvider is the manifest source provider that reads
+// manifests from a HTTP(S) URL, the URL may serve multiple YAML documents.
+type httpManifestSourceProvider struct {
+	client      *http.Client
+	allowedAPIs []string
+	log         *logrus.Entry
+}
+
+func NewHTTPLoader(allowedAPIs []string, log *logrus.Entry) (*httpManifestSourceProvider, error) {
+	return &httpManifestSourceProvider{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		allowedAPIs: allowedAPIs,
+		log:         log.WithField("context", "readManifests"),
+	}, nil
+}
+
+// GetFiles reads manifests from the uri, which is the URL of the manifests.
+func (p *httpManifestSourceProvider) GetFiles(uri string) ([]core.AbstractedManifest, error) {
+	resp, err := p.client.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status '%s' from '%s'", resp.Status, uri)
+	}
+
+	in, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeManifests(in, uri, p.allowedAPIs, p.log)
+}
+
+func (p *httpManifestSourceProvider) Name() string {
+	return "http"
+}
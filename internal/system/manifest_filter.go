@@ -0,0 +1,128 @@
+package system
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ManifestFilter filters files to be read as manifests by glob patterns.
+//
+// Pattern without `/` matches the base name of the file, otherwise it
+// matches the path relative to the source, where `**` matches zero or
+// more directories.
+type ManifestFilter struct {
+	// Include is the glob patterns of files to be read, defaults to YAML
+	// files if it is empty.
+	Include []string
+
+	// Exclude is the glob patterns of files (or directories) to be
+	// skipped, it takes precedence over include.
+	Exclude []string
+}
+
+var defaultIncludes = []string{"*.yaml", "*.yml"}
+
+// NewManifestFilter returns the filter from `include` and `exclude` query
+// parameters of the source URI, both of them could be repeated or be
+// comma-separated.
+func NewManifestFilter(params url.Values) (ManifestFilter, error) {
+	f := ManifestFilter{
+		Include: splitPatterns(params["include"]),
+		Exclude: splitPatterns(params["exclude"]),
+	}
+
+	for _, pattern := range append(f.Include, f.Exclude...) {
+		_, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), "")
+		if err != nil {
+			return f, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+		}
+	}
+
+	return f, nil
+}
+
+// Included returns true if the file (relative to the source) is to be
+// read as manifests.
+func (f ManifestFilter) Included(relPath string) bool {
+	if f.Excluded(relPath) {
+		return false
+	}
+
+	includes := f.Include
+	if len(includes) == 0 {
+		includes = defaultIncludes
+	}
+
+	for _, pattern := range includes {
+		if matchGlob(pattern, relPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Excluded returns true if the file or directory (relative to the source)
+// is to be skipped.
+func (f ManifestFilter) Excluded(relPath string) bool {
+	for _, pattern := range f.Exclude {
+		if matchGlob(pattern, relPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitPatterns(values []string) []string {
+	output := []string{}
+	for _, value := range values {
+		for _, pattern := range strings.Split(value, ",") {
+			if strings.TrimSpace(pattern) == "" {
+				continue
+			}
+			output = append(output, strings.TrimSpace(pattern))
+		}
+	}
+
+	return output
+}
+
+func matchGlob(pattern, relPath string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(relPath))
+		return ok
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+func matchSegments(patterns, segments []string) bool {
+	if len(patterns) == 0 {
+		return len(segments) == 0
+	}
+
+	if patterns[0] == "**" {
+		// `**` matches zero or more segments
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(patterns[1:], segments[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	ok, _ := path.Match(patterns[0], segments[0])
+	if !ok {
+		return false
+	}
+
+	return matchSegments(patterns[1:], segments[1:])
+}
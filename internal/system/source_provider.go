@@ -0,0 +1,288 @@
+package system
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/lexer"
+	"github.com/goccy/go-yaml/token"
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// ManifestSourceProvider is the interface of the provider that manifests
+// are read from, such as embed fs, directory, Git repository, etc.
+type ManifestSourceProvider interface {
+	// GetFiles reads manifests from the uri, where the uri is relative
+	// to the provider.
+	GetFiles(uri string) ([]core.AbstractedManifest, error)
+
+	// Name returns the name of the provider.
+	Name() string
+}
+
+// AssetSourceProvider is the optional interface of the provider that
+// serves the files along with the manifests, such as the files copied by
+// code templates.
+type AssetSourceProvider interface {
+	// Assets returns the fs rooted at the directory of the manifest
+	// file, where the uri is the uri read by the provider and the file
+	// path is the path annotated on the manifest.
+	Assets(uri string, filePath string) (fs.FS, error)
+}
+
+// ManifestSourceProviderFactory is the function that constructs the
+// provider from the location of the source URI, which is the source URI
+// without the scheme. It returns the provider and the uri to be read by
+// the provider.
+type ManifestSourceProviderFactory func(
+	location string, allowedAPIs []string, log *logrus.Entry,
+) (provider ManifestSourceProvider, uri string, err error)
+
+var registeredSourceProviders map[string]ManifestSourceProviderFactory = map[string]ManifestSourceProviderFactory{}
+
+func init() {
+	RegisterSourceProvider("dir://",
+		func(location string, allowedAPIs []string, log *logrus.Entry) (ManifestSourceProvider, string, error) {
+			dir, query, _ := strings.Cut(location, "?")
+
+			params, err := url.ParseQuery(query)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid query of directory manifest source: %w", err)
+			}
+
+			filter, err := NewManifestFilter(params)
+			if err != nil {
+				return nil, "", err
+			}
+
+			provider, err := NewDirectoryLoader(dir, allowedAPIs, log)
+			if err != nil {
+				return nil, "", err
+			}
+
+			return provider.WithFilter(filter), ".", nil
+		},
+	)
+	RegisterSourceProvider("git+",
+		func(location string, allowedAPIs []string, log *logrus.Entry) (ManifestSourceProvider, string, error) {
+			remote, query, _ := strings.Cut(location, "?")
+
+			params, err := url.ParseQuery(query)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid query of git manifest source: %w", err)
+			}
+
+			filter, err := NewManifestFilter(params)
+			if err != nil {
+				return nil, "", err
+			}
+
+			cacheDir, err := DefaultGitCacheDir()
+			if err != nil {
+				return nil, "", err
+			}
+
+			provider, err := NewGitLoader(remote, params.Get("ref"), cacheDir, allowedAPIs, log)
+			if err != nil {
+				return nil, "", err
+			}
+
+			return provider.WithFilter(filter), params.Get("dir"), nil
+		},
+	)
+	for _, scheme := range []string{"http://", "https://"} {
+		RegisterSourceProvider(scheme,
+			func(location string, allowedAPIs []string, log *logrus.Entry) (ManifestSourceProvider, string, error) {
+				provider, err := NewHTTPLoader(allowedAPIs, log)
+
+				return provider, scheme + location, err
+			},
+		)
+	}
+}
+
+// RegisterSourceProvider registers the provider factory by the scheme of
+// the source URI, such as `dir://`. Registering the same scheme again
+// replaces the previous factory.
+func RegisterSourceProvider(scheme string, factory ManifestSourceProviderFactory) error {
+	if scheme == "" {
+		return errors.New("`scheme` cannot be empty")
+	}
+	if factory == nil {
+		return errors.New("`factory` cannot be nil")
+	}
+
+	registeredSourceProviders[scheme] = factory
+
+	return nil
+}
+
+// SourceSchemes returns the sorted schemes of the registered providers.
+func SourceSchemes() []string {
+	schemes := lo.Keys(registeredSourceProviders)
+	slices.Sort(schemes)
+
+	return schemes
+}
+
+// ManifestSource is the manifest source configured by the source URI.
+type ManifestSource struct {
+	// URI is the source URI as configured, e.g. `dir://./manifests`.
+	URI string
+
+	// Provider is the provider resolved by the scheme of the URI.
+	Provider ManifestSourceProvider
+
+	// path is the uri to be read by the provider.
+	path string
+}
+
+// NewManifestSource resolves the provider of the source URI from the
+// registered providers.
+func NewManifestSource(uri string, allowedAPIs []string, log *logrus.Entry) (*ManifestSource, error) {
+	for _, scheme := range SourceSchemes() {
+		if !strings.HasPrefix(uri, scheme) {
+			continue
+		}
+
+		provider, path, err := registeredSourceProviders[scheme](
+			strings.TrimPrefix(uri, scheme), allowedAPIs, log)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure manifest source '%s': %w", uri, err)
+		}
+
+		return &ManifestSource{
+			URI:      uri,
+			Provider: provider,
+			path:     path,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported manifest source '%s', source must be prefixed with either %s",
+		uri, strings.Join(SourceSchemes(), ", "))
+}
+
+// Load reads manifests from the source, whereby every manifest is
+// annotated with the source URI.
+func (s *ManifestSource) Load() ([]core.AbstractedManifest, error) {
+	manifests, err := s.Provider.GetFiles(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load manifest source '%s': %w", s.URI, err)
+	}
+
+	for i := range manifests {
+		manifests[i].SetSource(s.URI)
+	}
+
+	return manifests, nil
+}
+
+// Assets returns the fs rooted at the directory of the manifest file of
+// the source, it fails if the provider serves manifests only.
+func (s *ManifestSource) Assets(filePath string) (fs.FS, error) {
+	provider, ok := s.Provider.(AssetSourceProvider)
+	if !ok {
+		return nil, fmt.Errorf("manifest source '%s' of provider '%s' does not serve files other than manifests",
+			s.URI, s.Provider.Name())
+	}
+
+	return provider.Assets(s.path, filePath)
+}
+
+// decodeManifests decodes every YAML document of the file into manifest,
+// whereby every manifest is annotated with the file path and its document
+// index within the file. Manifests of not allowed APIs are discarded.
+func decodeManifests(in []byte, filePath string, allowedAPIs []string, log *logrus.Entry) ([]core.AbstractedManifest, error) {
+	output := []core.AbstractedManifest{}
+
+	for index, document := range SplitDocuments(in) {
+		// empty document is counted, yet nothing to decode
+		if !HasContent(document) {
+			continue
+		}
+
+		var out core.AbstractedManifest
+		err := yaml.Unmarshal(document, &out)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode document %d of '%s': %w", index, filePath, err)
+		}
+
+		// check for API, discard silently if they are not relevant
+		api := fmt.Sprintf("%s/%s", out.APIVersion, out.Kind)
+		isAllowed := slices.Contains(allowedAPIs, api)
+		if !isAllowed {
+			log.WithField("illegalAPI", api).Warnf("illegal API '%s' found in document %d of '%s', skipping...", api, index, filePath)
+			continue
+		}
+
+		out.SetFilePath(filePath)
+		out.SetDocumentIndex(index)
+
+		output = append(output, out)
+	}
+
+	return output, nil
+}
+
+// SplitDocuments splits the YAML stream into documents by the document
+// header tokens of the YAML lexer, thus `---` within block scalars is never
+// read as a separator. Every document after a header is counted, including
+// the ones that are empty or only contain comments, so that the index of
+// the document matches the file, whereas the leading comments before the
+// first header are not a document.
+//
+// The decoder of goccy/go-yaml is not used, as it discards every document
+// after an empty (or comment-only) document.
+func SplitDocuments(in []byte) [][]byte {
+	headers := map[int]bool{}
+	for _, tk := range lexer.Tokenize(string(in)) {
+		if tk.Type == token.DocumentHeaderType {
+			headers[tk.Position.Line] = true
+		}
+	}
+
+	documents := [][]byte{}
+
+	var current bytes.Buffer
+	hasHeader := false
+
+	for i, line := range strings.SplitAfter(string(in), "\n") {
+		if !headers[i+1] {
+			current.WriteString(line)
+
+			continue
+		}
+
+		if hasHeader || HasContent(current.Bytes()) {
+			documents = append(documents, bytes.Clone(current.Bytes()))
+		}
+		current.Reset()
+		hasHeader = true
+	}
+	if hasHeader || HasContent(current.Bytes()) {
+		documents = append(documents, bytes.Clone(current.Bytes()))
+	}
+
+	return documents
+}
+
+// HasContent returns whether the document has any line other than blank
+// lines and comments.
+func HasContent(document []byte) bool {
+	for _, line := range strings.Split(string(document), "\n") {
+		content := strings.TrimSpace(line)
+		if content != "" && !strings.HasPrefix(content, "#") {
+			return true
+		}
+	}
+
+	return false
+}
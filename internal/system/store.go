@@ -0,0 +1,82 @@
+package system
+
+import (
+	"sync"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+)
+
+// Store is the storage backend of the environment database, keyed by the
+// hash of the manifest identity.
+type Store interface {
+	// Set adds or replaces the manifest of the key.
+	Set(key string, manifest core.AbstractedManifest) error
+
+	// Get returns the manifest of the key, it returns nil manifest if the
+	// key is not found.
+	Get(key string) (*core.AbstractedManifest, error)
+
+	// Range calls f sequentially for each manifest, it stops if f returns
+	// false.
+	Range(f func(key string, manifest core.AbstractedManifest) bool) error
+
+	// Delete removes the manifest of the key, it is no-op if the key is
+	// not found.
+	Delete(key string) error
+}
+
+// memoryStore is the in-memory store that never evicts, manifests vanish
+// at process exit.
+type memoryStore struct {
+	mu        sync.RWMutex
+	manifests map[string]core.AbstractedManifest
+}
+
+func NewMemoryStore() *memoryStore {
+	return &memoryStore{
+		manifests: map[string]core.AbstractedManifest{},
+	}
+}
+
+func (s *memoryStore) Set(key string, manifest core.AbstractedManifest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.manifests[key] = manifest
+
+	return nil
+}
+
+func (s *memoryStore) Get(key string) (*core.AbstractedManifest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	manifest, ok := s.manifests[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &manifest, nil
+}
+
+func (s *memoryStore) Range(f func(key string, manifest core.AbstractedManifest) bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for key, manifest := range s.manifests {
+		if !f(key, manifest) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.manifests, key)
+
+	return nil
+}
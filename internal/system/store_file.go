@@ -0,0 +1,213 @@
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// DefaultRetention is the number of manifests kept by the file store,
+// where the least recently set manifests are pruned beyond it.
+const DefaultRetention int = 100
+
+// fileStore is the store that persists every manifest as a JSON file in
+// the directory, thus manifests survive across invocations.
+type fileStore struct {
+	fs        afero.Fs
+	dir       string
+	log       *logrus.Entry
+	retention int
+}
+
+// NewFileStore returns the file-based store of the directory, where the
+// directory is created if it does not exist.
+func NewFileStore(dir string, log *logrus.Entry) (*fileStore, error) {
+	if dir == "" {
+		return nil, errors.New("store directory cannot be empty")
+	}
+
+	fs := afero.NewOsFs()
+
+	err := fs.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("unable to make store directory '%s': %w", dir, err)
+	}
+
+	return &fileStore{
+		fs:  fs,
+		dir: dir,
+		log: log.WithFields(logrus.Fields{
+			"context": "store",
+			"dir":     dir,
+		}),
+		retention: DefaultRetention,
+	}, nil
+}
+
+// WithRetention sets the number of manifests kept by the store, where
+// zero or less keeps every manifest.
+func (s *fileStore) WithRetention(retention int) *fileStore {
+	s.retention = retention
+
+	return s
+}
+
+// DefaultStateDir returns the directory of the persistent state, which is
+// `$XDG_STATE_HOME/alchemy`, else `$HOME/.local/state/alchemy`.
+func DefaultStateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "alchemy"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".local", "state", "alchemy"), nil
+}
+
+func (s *fileStore) Set(key string, manifest core.AbstractedManifest) error {
+	// manifest is normalised into plain map, so that it can be decoded
+	// back into abstracted manifest symmetrically.
+	var m map[string]any
+	err := mapstructure.Decode(manifest, &m)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// written into temporary file then renamed, so that the manifest is
+	// never half-written.
+	tmp := s.path(key) + ".tmp"
+	err = afero.WriteFile(s.fs, tmp, b, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = s.fs.Rename(tmp, s.path(key))
+	if err != nil {
+		return err
+	}
+
+	s.log.Tracef("persisted '%s' under namespace '%s'", manifest.Metadata.Name, manifest.Metadata.Namespace)
+
+	return s.prune()
+}
+
+// prune removes the least recently set manifests beyond the retention.
+func (s *fileStore) prune() error {
+	if s.retention <= 0 {
+		return nil
+	}
+
+	fileInfos, err := afero.ReadDir(s.fs, s.dir)
+	if err != nil {
+		return err
+	}
+
+	fileInfos = slices.DeleteFunc(fileInfos, func(fileInfo os.FileInfo) bool {
+		return fileInfo.IsDir() || filepath.Ext(fileInfo.Name()) != ".json"
+	})
+	if len(fileInfos) <= s.retention {
+		return nil
+	}
+
+	slices.SortStableFunc(fileInfos, func(a, b os.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+
+	for _, fileInfo := range fileInfos[:len(fileInfos)-s.retention] {
+		err = s.fs.Remove(filepath.Join(s.dir, fileInfo.Name()))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		s.log.Tracef("pruned '%s' beyond retention of %d", fileInfo.Name(), s.retention)
+	}
+
+	return nil
+}
+
+func (s *fileStore) Get(key string) (*core.AbstractedManifest, error) {
+	b, err := afero.ReadFile(s.fs, s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decode(b)
+}
+
+func (s *fileStore) Range(f func(key string, manifest core.AbstractedManifest) bool) error {
+	fileInfos, err := afero.ReadDir(s.fs, s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() || filepath.Ext(fileInfo.Name()) != ".json" {
+			continue
+		}
+
+		b, err := afero.ReadFile(s.fs, filepath.Join(s.dir, fileInfo.Name()))
+		if err != nil {
+			return err
+		}
+
+		manifest, err := s.decode(b)
+		if err != nil {
+			return fmt.Errorf("unable to decode persisted manifest '%s': %w", fileInfo.Name(), err)
+		}
+
+		if !f(strings.TrimSuffix(fileInfo.Name(), ".json"), *manifest) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *fileStore) Delete(key string) error {
+	err := s.fs.Remove(s.path(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *fileStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *fileStore) decode(b []byte) (*core.AbstractedManifest, error) {
+	var m map[string]any
+	err := json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest core.AbstractedManifest
+	err = mapstructure.Decode(m, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
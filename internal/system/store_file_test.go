@@ -0,0 +1,114 @@
+package system
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nicholastcs/alchemy/internal/apis/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentDBAcrossInvocations(t *testing.T) {
+	dir := t.TempDir()
+	persistedAPIs := []string{"alchemy.io/mock/Persisted"}
+
+	newManifest := func(kind string) core.AbstractedManifest {
+		m := core.AbstractedManifest{
+			Base: core.Base{
+				APIVersion: "alchemy.io/mock",
+				Kind:       kind,
+				Metadata: core.Metadata{
+					Name: "testing",
+				},
+			},
+			Spec: map[string]any{
+				"result": map[string]any{"name": "alchemy"},
+			},
+		}
+		m.Status.SetCondition(core.ResourceReady, true)
+		m.Status.AdditionalInfo = map[string]any{
+			"generatedFiles": []any{"main.tf"},
+		}
+
+		return m
+	}
+
+	store, err := NewFileStore(dir, logT)
+	require.NoError(t, err, "must create file store")
+
+	u, err := NewPersistentDB(logT, store, persistedAPIs)
+	require.NoError(t, err, "must create persistent db")
+
+	require.NoError(t, u.SetAll([]core.AbstractedManifest{
+		newManifest("Persisted"), newManifest("Ephemeral"),
+	}), "must can set data")
+
+	// the next invocation
+	store, err = NewFileStore(dir, logT)
+	require.NoError(t, err, "must create file store")
+
+	u, err = NewPersistentDB(logT, store, persistedAPIs)
+	require.NoError(t, err, "must create persistent db")
+
+	out, err := u.Get("alchemy.io/mock", "Persisted", "testing", "default")
+	require.NoError(t, err, "must get persisted data")
+	require.NotNil(t, out, "persisted manifest must survive")
+	assert.Equal(t, map[string]any{"result": map[string]any{"name": "alchemy"}}, out.Spec, "spec must be equal")
+	assert.True(t, out.Status.GetCondition(core.ResourceReady), "condition must be retained")
+	assert.Equal(t, []any{"main.tf"}, out.Status.AdditionalInfo["generatedFiles"], "additional info must be retained")
+
+	out, err = u.Get("alchemy.io/mock", "Ephemeral", "testing", "default")
+	require.NoError(t, err, "must not emit error")
+	assert.Nil(t, out, "not persisted manifest must vanish")
+
+	require.NoError(t, u.Delete("alchemy.io/mock", "Persisted", "testing", "default"), "must can delete data")
+
+	m, err := store.Get(hashKey(t, u, newManifest("Persisted")))
+	require.NoError(t, err, "must not emit error")
+	assert.Nil(t, m, "deleted manifest must be removed from store")
+}
+
+func hashKey(t *testing.T, u *Db, m core.AbstractedManifest) string {
+	hash, err := u.manifestHash(m.Base)
+	require.NoError(t, err, "must hash manifest")
+
+	return hash
+}
+
+func TestFileStoreRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir, logT)
+	require.NoError(t, err, "must create file store")
+	store = store.WithRetention(2)
+
+	newManifest := func(name string) core.AbstractedManifest {
+		return core.AbstractedManifest{
+			Base: core.Base{
+				APIVersion: "alchemy.io/mock",
+				Kind:       "Persisted",
+				Metadata:   core.Metadata{Name: name, Namespace: "default"},
+			},
+		}
+	}
+
+	// the least recently set manifest is the first one
+	now := time.Now()
+	for i, key := range []string{"first", "second"} {
+		require.NoError(t, store.Set(key, newManifest(key)), "must can set data")
+
+		mtime := now.Add(time.Duration(i-2) * time.Hour)
+		require.NoError(t, os.Chtimes(store.path(key), mtime, mtime), "must change mtime")
+	}
+	require.NoError(t, store.Set("third", newManifest("third")), "must can set data")
+
+	keys := []string{}
+	require.NoError(t, store.Range(func(key string, _ core.AbstractedManifest) bool {
+		keys = append(keys, key)
+
+		return true
+	}))
+	assert.ElementsMatch(t, []string{"second", "third"}, keys, "manifest beyond retention must be pruned")
+}
@@ -168,3 +168,27 @@ func PrintTableV2(header []string, data [][]string, caption string) {
 
 	fmt.Println(sb.String())
 }
+
+// PrintUnifiedDiff prints the unified diff to the terminal, whereby added
+// and removed lines are colored.
+func PrintUnifiedDiff(diff string) {
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color("#50C878")).Render
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color("#EE4B2B")).Render
+	hunk := lipgloss.NewStyle().Foreground(lipgloss.Color("#00B7EB")).Render
+	header := lipgloss.NewStyle().Bold(true).Render
+
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Println(header(line))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Println(hunk(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Println(added(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println(removed(line))
+		default:
+			fmt.Println(line)
+		}
+	}
+}
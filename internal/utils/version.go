@@ -0,0 +1,23 @@
+package utils
+
+import "runtime/debug"
+
+// version is the version of Alchemy, which is set during build with
+// `-ldflags "-X github.com/nicholastcs/alchemy/internal/utils.version=<version>"`.
+var version string
+
+// Version returns the version of Alchemy, it falls back to the module
+// version of the build info (e.g. installed by `go install`), else
+// `(devel)`.
+func Version() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+
+	return "(devel)"
+}
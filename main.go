@@ -10,7 +10,7 @@ import (
 	"github.com/nicholastcs/alchemy/internal/utils"
 )
 
-//go:embed embed/*.yaml
+//go:embed embed
 var fs embed.FS
 
 func main() {